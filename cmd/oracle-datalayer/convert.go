@@ -0,0 +1,62 @@
+// Copyright 2024 MIMIRO AS
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mimiro-io/oracle-datalayer/internal/legacy/conf"
+	"github.com/mimiro-io/oracle-datalayer/internal/legacy/convert"
+)
+
+// convertLegacy prints the common-datalayer configuration for a legacy configuration file
+func convertLegacy(args []string) int {
+	flags := flag.NewFlagSet("convert-legacy", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: oracle-datalayer convert-legacy <legacy config file>")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	b, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read legacy config: %v\n", err)
+		return 1
+	}
+	legacy := &conf.Datalayer{}
+	if err = json.Unmarshal(b, legacy); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse legacy config: %v\n", err)
+		return 1
+	}
+	config, notes := convert.Convert(legacy)
+	for _, note := range notes {
+		fmt.Fprintf(os.Stderr, "note: %s\n", note)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(config); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write config: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 MIMIRO AS
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	layer "github.com/mimiro-io/oracle-datalayer/internal"
+)
+
+// generate prints a dataset definition for an existing table or view.
+// the database connection is configured with the system_config of the config folder.
+func generate(args []string) int {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	configFolder := flags.String("config", "./config", "config folder with the system_config of the database")
+	name := flags.String("name", "", "dataset name, default is the table name in lower case")
+	baseURI := flags.String("base-uri", "", "base uri of entity ids and properties")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: oracle-datalayer generate [flags] <table or view>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	config, err := layer.LoadConfig(*configFolder)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
+		return 1
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	def, notes, err := layer.GenerateDatasetDefinition(ctx, config, flags.Arg(0), *name, *baseURI)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to generate dataset definition: %v\n", err)
+		return 1
+	}
+	for _, note := range notes {
+		fmt.Fprintf(os.Stderr, "note: %s\n", note)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(def); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write dataset definition: %v\n", err)
+		return 1
+	}
+	return 0
+}
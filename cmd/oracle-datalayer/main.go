@@ -22,10 +22,16 @@ import (
 )
 
 func main() {
+	args := os.Args[1:]
+	if len(args) >= 1 && args[0] == "generate" {
+		os.Exit(generate(args[1:]))
+	}
+	if len(args) >= 1 && args[0] == "convert-legacy" {
+		os.Exit(convertLegacy(args[1:]))
+	}
 	// either pass in command argument or set DATALAYER_CONFIG_PATH environment variable.
 	// if nothing is set, the ServiceRunner defaults to ./config
 	configFolderLocation := ""
-	args := os.Args[1:]
 	if len(args) >= 1 {
 		configFolderLocation = args[0]
 	}
@@ -1,18 +1,59 @@
 package layer
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	common "github.com/mimiro-io/common-datalayer"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
 	// dataset mapping config
-	TableName      = "table_name"
-	FlushThreshold = "flush_threshold"
-	AppendMode     = "append_mode"
-	SinceColumn    = "since_column"
+	TableName       = "table_name"
+	Query           = "query"
+	FlushThreshold  = "flush_threshold"
+	AppendMode      = "append_mode"
+	SinceColumn     = "since_column"
+	FullSyncTable   = "full_sync_table"
+	KeyColumns      = "key_columns"
+	SnapshotSCN     = "snapshot_scn"
+	ChangeTracking  = "change_tracking"
+	RequireRowDeps  = "require_rowdependencies"
+	ChangeLog       = "change_log"
+	RecordedColumn  = "recorded_column"
+	CreateTable     = "create_table"
+	EvolveSchema    = "evolve_schema"
+	Connection      = "connection"
+	CommitMode      = "commit_mode"
+	DeleteMode      = "delete_mode"
+	DeletedColumn   = "deleted_column"
+	DeletedAtColumn = "deleted_at_column"
+	OnlyNewer       = "only_newer"
+	ErrorPolicy     = "error_policy"
+	RejectTable     = "reject_table"
+	RejectFile      = "reject_file"
+
+	// change tracking modes
+	ChangeTrackingRowSCN   = "ora_rowscn"
+	ChangeTrackingLogMiner = "logminer"
+
+	// commit modes of incremental writes
+	CommitPerRequest = "per_request"
+	CommitPerFlush   = "per_flush"
+	CommitAutonomous = "autonomous"
+
+	// delete modes
+	DeleteHard   = "hard"
+	DeleteSoft   = "soft"
+	DeleteIgnore = "ignore"
+
+	// error policies of writes
+	ErrorPolicyFail   = "fail"
+	ErrorPolicyReject = "reject"
 
 	// native system config
 	OracleHostname = "oracle_hostname"
@@ -20,6 +61,15 @@ const (
 	OracleDB       = "oracle_db"
 	OracleUser     = "oracle_user"
 	OraclePassword = "oracle_password"
+
+	// optional connection pool settings in system config
+	MaxOpenConns    = "max_open_conns"
+	MaxIdleConns    = "max_idle_conns"
+	ConnMaxLifetime = "conn_max_lifetime"
+	ConnMaxIdleTime = "conn_max_idle_time"
+
+	// named connections in system config, referenced by the connection option of datasets
+	Connections = "connections"
 )
 
 func EnvOverrides(config *common.Config) error {
@@ -40,19 +90,163 @@ func (c oraConf) str(key string) string {
 	return c.NativeSystemConfig[key].(string)
 }
 
-func (c oraConf) int(key string) int {
-	intVal, err := strconv.Atoi(c.NativeSystemConfig[key].(string))
+// int reads an integer, which can be given as json number or as string (from env overrides)
+func (c oraConf) int(key string) (int, error) {
+	switch v := c.NativeSystemConfig[key].(type) {
+	case float64:
+		return int(v), nil
+	case string:
+		intVal, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("system_config key %s is not an int: %s", key, v)
+		}
+		return intVal, nil
+	default:
+		return 0, fmt.Errorf("system_config key %s is not an int: %v", key, v)
+	}
+}
+
+// optInt reads an optional integer
+func (c oraConf) optInt(key string, defaultValue int) (int, error) {
+	if c.NativeSystemConfig[key] == nil {
+		return defaultValue, nil
+	}
+	return c.int(key)
+}
+
+// optDuration reads an optional duration like "90s" or "30m"
+func (c oraConf) optDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	v, ok := c.NativeSystemConfig[key]
+	if !ok || v == nil {
+		return defaultValue, nil
+	}
+	str, ok := v.(string)
+	if !ok {
+		return 0, fmt.Errorf("system_config key %s must be a duration string like 90s, 30m or 1h", key)
+	}
+	d, err := time.ParseDuration(str)
 	if err != nil {
-		panic(fmt.Errorf("NativeSystemConfig key %s is not an int", key))
+		return 0, fmt.Errorf("system_config key %s must be a duration string like 90s, 30m or 1h: %w", key, err)
 	}
-	return intVal
+	return d, nil
+}
+
+// connectionNames returns the sorted names of the connections in system_config
+func (c oraConf) connectionNames() []string {
+	connections, _ := c.NativeSystemConfig[Connections].(map[string]any)
+	var names []string
+	for name := range connections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// connection returns the settings of a named connection. keys that are not set for the connection
+// are taken from the top level of system_config, so a connection can for example only change the user.
+func (c oraConf) connection(name string) (oraConf, error) {
+	connections, _ := c.NativeSystemConfig[Connections].(map[string]any)
+	settings, ok := connections[name].(map[string]any)
+	if !ok {
+		return oraConf{}, fmt.Errorf("connection %s is not defined in system_config %s", name, Connections)
+	}
+	merged := common.NativeSystemConfig{}
+	for k, v := range c.NativeSystemConfig {
+		if k != Connections {
+			merged[k] = v
+		}
+	}
+	for k, v := range settings {
+		merged[k] = v
+	}
+	return oraConf{merged}, nil
 }
 
 func oracleConf(config *common.Config) oraConf {
+	if config == nil {
+		return oraConf{}
+	}
 	return oraConf{config.NativeSystemConfig}
 }
 
+// connectionName returns the name of the connection of a dataset, empty for the default connection
+func connectionName(definition *common.DatasetDefinition) string {
+	name, _ := definition.SourceConfig[Connection].(string)
+	return name
+}
+
+// keyColumns returns the columns that identify a row in the dataset table.
+// key_columns in source config can declare a (composite) natural key, otherwise
+// the identity column of the incoming (or outgoing) mapping is used.
+func keyColumns(definition *common.DatasetDefinition) ([]string, common.LayerError) {
+	if keys, ok := definition.SourceConfig[KeyColumns]; ok {
+		list, ok := keys.([]any)
+		if !ok || len(list) == 0 {
+			return nil, ErrGeneric("%s must be a non-empty list of column names in dataset %s", KeyColumns, definition.DatasetName)
+		}
+		var cols []string
+		for _, k := range list {
+			col, ok := k.(string)
+			if !ok || col == "" {
+				return nil, ErrGeneric("%s must be a non-empty list of column names in dataset %s", KeyColumns, definition.DatasetName)
+			}
+			cols = append(cols, col)
+		}
+		return cols, nil
+	}
+	if definition.IncomingMappingConfig != nil {
+		for _, m := range definition.IncomingMappingConfig.PropertyMappings {
+			if m.IsIdentity {
+				return []string{m.Property}, nil
+			}
+		}
+	}
+	if definition.OutgoingMappingConfig != nil {
+		for _, m := range definition.OutgoingMappingConfig.PropertyMappings {
+			if m.IsIdentity {
+				return []string{m.Property}, nil
+			}
+		}
+	}
+	return []string{"id"}, nil
+}
+
+// unmappedKeyColumns returns an error for each key column that is not part of the incoming mapping.
+// the merge statement of upsert mode joins on the key columns, so they must be mapped
+func unmappedKeyColumns(definition *common.DatasetDefinition, keyCols []string) []error {
+	var errs []error
+	var incoming []string
+	for _, pm := range definition.IncomingMappingConfig.PropertyMappings {
+		incoming = append(incoming, pm.Property)
+	}
+	for _, k := range keyCols {
+		if !containsColumn(incoming, k) {
+			errs = append(errs, fmt.Errorf("key column %s is not mapped in incoming mapping", k))
+		}
+	}
+	return errs
+}
+
 func (dl *OracleDatalayer) UpdateConfiguration(config *common.Config) common.LayerError {
+	// the new configuration is only applied if it is valid, otherwise the previous configuration is kept.
+	// connections are opened at startup, so named connections are looked up in the initial system config.
+	// datasets of disconnected databases are only checked against the database once they are used
+	var pools map[string]*sql.DB
+	if dl.db != nil {
+		pools = map[string]*sql.DB{}
+		for name, db := range dl.connections {
+			if db.healthy() == nil {
+				pools[name] = db.pool
+			}
+		}
+		if dl.db.healthy() == nil {
+			pools[""] = dl.db.pool
+		}
+	}
+	if err := validateDatasets(oracleConf(dl.config), pools, config.DatasetDefinitions); err != nil {
+		return ErrInvalidConfig(err)
+	}
+
 	existingDatasets := map[string]bool{}
 	// update existing datasets
 	for k, v := range dl.datasets {
@@ -60,6 +254,8 @@ func (dl *OracleDatalayer) UpdateConfiguration(config *common.Config) common.Lay
 			if k == dsd.DatasetName {
 				existingDatasets[k] = true
 				v.datasetDefinition = dsd
+				v.db = dl.connection(dsd)
+				v.invalidateSchema()
 			}
 		}
 	}
@@ -75,7 +271,7 @@ func (dl *OracleDatalayer) UpdateConfiguration(config *common.Config) common.Lay
 		if _, found := existingDatasets[dsd.DatasetName]; !found {
 			dl.datasets[dsd.DatasetName] = &Dataset{
 				logger:            dl.logger,
-				db:                dl.db,
+				db:                dl.connection(dsd),
 				datasetDefinition: dsd,
 			}
 		}
@@ -92,3 +288,32 @@ func (dl *OracleDatalayer) UpdateConfiguration(config *common.Config) common.Lay
 
 	return nil
 }
+
+// validateQuery makes sure a custom query parses, and that it returns the columns used by the dataset.
+// the query is described by executing it with a condition that returns no rows
+func validateQuery(db *sql.DB, definition *common.DatasetDefinition) error {
+	from, _ := querySource(definition)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, "SELECT * FROM "+from+" WHERE 1=0", queryArgs(definition, nil, 0)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	required := []string{sinceColumn(definition)}
+	if omc := definition.OutgoingMappingConfig; omc != nil && !omc.MapAll {
+		for _, pm := range omc.PropertyMappings {
+			required = append(required, pm.Property)
+		}
+	}
+	for _, col := range required {
+		if col != "" && columnIndex(columns, col) < 0 {
+			return fmt.Errorf("query does not return column %s", col)
+		}
+	}
+	return nil
+}
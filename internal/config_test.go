@@ -2,6 +2,7 @@ package layer
 
 import (
 	"testing"
+	"time"
 
 	common "github.com/mimiro-io/common-datalayer"
 )
@@ -15,13 +16,24 @@ func testDeps() (*common.Config, common.Metrics, common.Logger) {
 				"oracle_hostname": "test",
 				"oracle_port":     "1234",
 			},
-			DatasetDefinitions: []*common.DatasetDefinition{
-				{DatasetName: "test"},
-			},
+			DatasetDefinitions: []*common.DatasetDefinition{testDefinition(nil)},
 		},
 		nil, nil
 }
 
+// testDefinition returns a valid definition of the dataset "test", with extra source config options
+func testDefinition(source map[string]any) *common.DatasetDefinition {
+	sc := map[string]any{TableName: "test"}
+	for k, v := range source {
+		sc[k] = v
+	}
+	return &common.DatasetDefinition{
+		DatasetName:           "test",
+		SourceConfig:          sc,
+		OutgoingMappingConfig: &common.OutgoingMappingConfig{MapAll: true},
+	}
+}
+
 func TestConfig(t *testing.T) {
 	var subject common.DataLayerService
 	setup := func() {
@@ -43,7 +55,7 @@ func TestConfig(t *testing.T) {
 	t.Run("should add dataset definitions", func(t *testing.T) {
 		setup()
 		if subject.UpdateConfiguration(&common.Config{
-			DatasetDefinitions: []*common.DatasetDefinition{{DatasetName: "test"}},
+			DatasetDefinitions: []*common.DatasetDefinition{testDefinition(nil)},
 		}) != nil {
 			t.Fatal("failed to add dataset definition")
 		}
@@ -54,14 +66,14 @@ func TestConfig(t *testing.T) {
 		if ds == nil {
 			t.Fatal("dataset is nil")
 		}
-		if len(ds.MetaData()) != 0 {
-			t.Fatal("empty here means non implicit")
+		if len(ds.MetaData()) != 1 {
+			t.Fatal("only table_name here means non implicit")
 		}
 	})
 	t.Run("should update dataset definitions", func(t *testing.T) {
 		setup()
 		if subject.UpdateConfiguration(&common.Config{
-			DatasetDefinitions: []*common.DatasetDefinition{{DatasetName: "test"}},
+			DatasetDefinitions: []*common.DatasetDefinition{testDefinition(nil)},
 		}) != nil {
 			t.Fatal("failed to add dataset definition")
 		}
@@ -72,12 +84,12 @@ func TestConfig(t *testing.T) {
 		if ds == nil {
 			t.Fatal("dataset is nil")
 		}
-		if len(ds.MetaData()) != 0 {
-			t.Fatal("empty here means non implicit")
+		if len(ds.MetaData()) != 1 {
+			t.Fatal("only table_name here means non implicit")
 		}
 
 		if subject.UpdateConfiguration(&common.Config{
-			DatasetDefinitions: []*common.DatasetDefinition{{DatasetName: "test", SourceConfig: map[string]any{"test": "test"}}},
+			DatasetDefinitions: []*common.DatasetDefinition{testDefinition(map[string]any{"test": "test"})},
 		}) != nil {
 			t.Fatal("failed to update dataset definition")
 		}
@@ -95,7 +107,7 @@ func TestConfig(t *testing.T) {
 	t.Run("should remove dataset definitions", func(t *testing.T) {
 		setup()
 		if subject.UpdateConfiguration(&common.Config{
-			DatasetDefinitions: []*common.DatasetDefinition{{DatasetName: "test"}},
+			DatasetDefinitions: []*common.DatasetDefinition{testDefinition(nil)},
 		}) != nil {
 			t.Fatal("failed to add dataset definition")
 		}
@@ -106,8 +118,8 @@ func TestConfig(t *testing.T) {
 		if ds == nil {
 			t.Fatal("dataset is nil")
 		}
-		if len(ds.MetaData()) != 0 {
-			t.Fatal("empty here means non implicit")
+		if len(ds.MetaData()) != 1 {
+			t.Fatal("only table_name here means non implicit")
 		}
 
 		if subject.UpdateConfiguration(&common.Config{}) != nil {
@@ -118,6 +130,52 @@ func TestConfig(t *testing.T) {
 			t.Fatal("expected dataset not found error, all datasets are deleted herer")
 		}
 	})
+	t.Run("key columns", func(t *testing.T) {
+		def := &common.DatasetDefinition{
+			DatasetName:  "test",
+			SourceConfig: map[string]any{},
+			IncomingMappingConfig: &common.IncomingMappingConfig{
+				PropertyMappings: []*common.EntityToItemPropertyMapping{{Property: "code", IsIdentity: true}},
+			},
+		}
+		keys, err := keyColumns(def)
+		if err != nil || len(keys) != 1 || keys[0] != "code" {
+			t.Fatalf("expected identity column as key, got %v, %v", keys, err)
+		}
+		def.SourceConfig[KeyColumns] = []any{"tenant", "code"}
+		keys, err = keyColumns(def)
+		if err != nil || len(keys) != 2 || keys[0] != "tenant" || keys[1] != "code" {
+			t.Fatalf("expected configured key columns, got %v, %v", keys, err)
+		}
+		def.SourceConfig[KeyColumns] = "tenant"
+		_, err = keyColumns(def)
+		if err == nil {
+			t.Fatal("expected error for invalid key_columns")
+		}
+	})
+	t.Run("optional pool settings", func(t *testing.T) {
+		c := oraConf{common.NativeSystemConfig{
+			MaxOpenConns:    float64(10),
+			MaxIdleConns:    "5",
+			ConnMaxLifetime: "30m",
+			ConnMaxIdleTime: 5,
+		}}
+		if v, err := c.optInt(MaxOpenConns, 0); err != nil || v != 10 {
+			t.Fatalf("expected 10, got %v, %v", v, err)
+		}
+		if v, err := c.optInt(MaxIdleConns, 0); err != nil || v != 5 {
+			t.Fatalf("expected 5, got %v, %v", v, err)
+		}
+		if v, err := c.optInt("missing", 2); err != nil || v != 2 {
+			t.Fatalf("expected default 2, got %v, %v", v, err)
+		}
+		if v, err := c.optDuration(ConnMaxLifetime, 0); err != nil || v != 30*time.Minute {
+			t.Fatalf("expected 30m, got %v, %v", v, err)
+		}
+		if _, err := c.optDuration(ConnMaxIdleTime, 0); err == nil {
+			t.Fatal("expected error for non-string duration")
+		}
+	})
 	t.Run("with EnvOverrides", func(t *testing.T) {
 		t.Setenv("ORACLE_DB", "overridden_test")
 		t.Run("should override config with env vars", func(t *testing.T) {
@@ -0,0 +1,206 @@
+package layer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	go_ora "github.com/sijms/go-ora/v2"
+)
+
+// tableColumn describes a column of a table, as found in ALL_TAB_COLUMNS
+type tableColumn struct {
+	Position  int
+	Name      string
+	DataType  string
+	Length    int // character length of text columns
+	Precision sql.NullInt64
+	Scale     sql.NullInt64
+	Nullable  bool
+}
+
+// tableColumns looks up the columns of a table, keyed by upper case column name.
+// the result is empty if the table does not exist
+func tableColumns(ctx context.Context, db *sql.DB, table string) (map[string]tableColumn, error) {
+	owner, name := splitTableName(table)
+	rows, err := db.QueryContext(ctx, "SELECT COLUMN_ID, COLUMN_NAME, DATA_TYPE, CHAR_LENGTH, DATA_PRECISION, DATA_SCALE, NULLABLE "+
+		"FROM ALL_TAB_COLUMNS WHERE TABLE_NAME = :1 AND OWNER = NVL(:2, USER) ORDER BY COLUMN_ID", name, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols := map[string]tableColumn{}
+	for rows.Next() {
+		var c tableColumn
+		var nullable string
+		if err = rows.Scan(&c.Position, &c.Name, &c.DataType, &c.Length, &c.Precision, &c.Scale, &nullable); err != nil {
+			return nil, err
+		}
+		c.Nullable = nullable == "Y"
+		cols[strings.ToUpper(c.Name)] = c
+	}
+	return cols, rows.Err()
+}
+
+var numberPattern = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+// timeLayouts are the accepted text formats of timestamps
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// convertValue converts a mapped entity value into a bind value for a column of the given type.
+// all values of a column are converted to the same go type, so that array binds use one oracle type per column
+func convertValue(col tableColumn, v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	dataType := col.DataType
+	switch {
+	case dataType == "NUMBER" || dataType == "FLOAT" || dataType == "BINARY_FLOAT" || dataType == "BINARY_DOUBLE":
+		return toNumber(v)
+	case dataType == "BOOLEAN":
+		b, err := toBool(v)
+		if err != nil {
+			return nil, err
+		}
+		// bound as text, like booleans in columns of unknown type
+		return strconv.FormatBool(b), nil
+	case dataType == "DATE" || strings.HasPrefix(dataType, "TIMESTAMP"):
+		return toTime(v)
+	case dataType == "CLOB":
+		return go_ora.Clob{String: toText(v), Valid: true}, nil
+	case dataType == "NCLOB":
+		return go_ora.NClob{String: toText(v), Valid: true}, nil
+	case dataType == "RAW" || dataType == "LONG RAW":
+		return toBytes(v)
+	case dataType == "BLOB":
+		b, err := toBytes(v)
+		if err != nil {
+			return nil, err
+		}
+		return go_ora.Blob{Data: b}, nil
+	case dataType == "JSON":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case strings.Contains(dataType, "CHAR") || dataType == "LONG":
+		return toText(v), nil
+	}
+	// unknown column types are bound as before
+	return bindVal(v), nil
+}
+
+func toNumber(v any) (any, error) {
+	var num *go_ora.Number
+	var err error
+	switch val := v.(type) {
+	case bool:
+		if val {
+			num, err = go_ora.NewNumberFromInt64(1)
+		} else {
+			num, err = go_ora.NewNumberFromInt64(0)
+		}
+	case int:
+		num, err = go_ora.NewNumberFromInt64(int64(val))
+	case int32:
+		num, err = go_ora.NewNumberFromInt64(int64(val))
+	case int64:
+		num, err = go_ora.NewNumberFromInt64(val)
+	case uint64:
+		num, err = go_ora.NewNumberFromUint64(val)
+	case float32:
+		num, err = go_ora.NewNumberFromFloat(float64(val))
+	case float64:
+		num, err = go_ora.NewNumberFromFloat(val)
+	case string:
+		s := strings.TrimPrefix(strings.TrimSpace(val), "+")
+		if !numberPattern.MatchString(s) {
+			return nil, fmt.Errorf("%q is not a number", val)
+		}
+		num, err = go_ora.NewNumberFromString(strings.ToLower(s))
+	default:
+		return nil, fmt.Errorf("cannot convert %T to number", v)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return *num, nil
+}
+
+func toBool(v any) (bool, error) {
+	switch val := v.(type) {
+	case bool:
+		return val, nil
+	case string:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return false, fmt.Errorf("%q is not a boolean", val)
+		}
+		return b, nil
+	case int, int32, int64, uint64, float32, float64:
+		f, _ := strconv.ParseFloat(fmt.Sprint(val), 64)
+		if f != 0 && f != 1 {
+			return false, fmt.Errorf("%v is not a boolean", val)
+		}
+		return f == 1, nil
+	}
+	return false, fmt.Errorf("cannot convert %T to boolean", v)
+}
+
+func toTime(v any) (any, error) {
+	switch val := v.(type) {
+	case time.Time:
+		return val, nil
+	case string:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, val); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not a RFC3339 timestamp", val)
+	}
+	return nil, fmt.Errorf("cannot convert %T to timestamp", v)
+}
+
+// toText formats values without exponent notation
+func toText(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case bool:
+		return strconv.FormatBool(val)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// toBytes accepts raw bytes or base64 encoded text
+func toBytes(v any) ([]byte, error) {
+	switch val := v.(type) {
+	case []byte:
+		return val, nil
+	case string:
+		for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+			if b, err := enc.DecodeString(val); err == nil {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not base64 encoded", val)
+	}
+	return nil, fmt.Errorf("cannot convert %T to bytes", v)
+}
@@ -0,0 +1,70 @@
+package layer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	go_ora "github.com/sijms/go-ora/v2"
+)
+
+func TestConvertValue(t *testing.T) {
+	number := func(s string) go_ora.Number {
+		n, err := go_ora.NewNumberFromString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *n
+	}
+	ts := time.Date(2024, 3, 1, 12, 30, 0, 500, time.UTC)
+	for _, tc := range []struct {
+		name     string
+		dataType string
+		in       any
+		expected any
+	}{
+		{"nil stays nil", "NUMBER", nil, nil},
+		{"integer to number", "NUMBER", int64(42), number("42")},
+		{"numeric string to number", "NUMBER", "12345678901234567890.123", number("12345678901234567890.123")},
+		{"exponent string to number", "NUMBER", "1.5E3", number("1.5e3")},
+		{"bool to number", "NUMBER", true, number("1")},
+		{"bool to native boolean", "BOOLEAN", false, "false"},
+		{"bool text to native boolean", "BOOLEAN", "true", "true"},
+		{"rfc3339 to timestamp", "TIMESTAMP(6)", ts.Format(time.RFC3339Nano), ts},
+		{"date only to date", "DATE", "2024-03-01", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"text to clob", "CLOB", "long text", go_ora.Clob{String: "long text", Valid: true}},
+		{"float to varchar without exponent", "VARCHAR2", 1e21, "1000000000000000000000"},
+		{"base64 to raw", "RAW", "QUFB", []byte("AAA")},
+		{"base64 to blob", "BLOB", "QUFB", go_ora.Blob{Data: []byte("AAA")}},
+		{"object to json", "JSON", map[string]any{"a": 1}, `{"a":1}`},
+		{"unknown type as before", "ROWID", true, "true"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := convertValue(tableColumn{Name: "C", DataType: tc.dataType}, tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(v, tc.expected) {
+				t.Fatalf("expected %#v, got %#v", tc.expected, v)
+			}
+		})
+	}
+
+	t.Run("should reject values that cannot be converted", func(t *testing.T) {
+		for _, tc := range []struct {
+			dataType string
+			in       any
+		}{
+			{"NUMBER", "12,5"},
+			{"NUMBER", "abc"},
+			{"BOOLEAN", 2},
+			{"TIMESTAMP", "01.03.2024"},
+			{"DATE", 1709296200},
+			{"RAW", "not base64!"},
+		} {
+			if _, err := convertValue(tableColumn{Name: "C", DataType: tc.dataType}, tc.in); err == nil {
+				t.Fatalf("expected error for %v in %s column", tc.in, tc.dataType)
+			}
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package layer
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	common "github.com/mimiro-io/common-datalayer"
+)
+
+// deletion describes how deleted entities are applied to the dataset table
+type deletion struct {
+	mode     string // DeleteHard, DeleteSoft or DeleteIgnore
+	column   string // flag column of soft deleted rows
+	atColumn string // optional timestamp column of soft deleted rows
+	flag     string // sql literal of a set flag, depends on the type of the flag column
+}
+
+// deletionOf reads delete_mode and the soft delete columns of a dataset definition.
+// the flag column defaults to the deleted column of the incoming mapping
+func deletionOf(definition *common.DatasetDefinition) (deletion, error) {
+	d := deletion{mode: DeleteHard}
+	if m, ok := definition.SourceConfig[DeleteMode].(string); ok && m != "" {
+		d.mode = m
+	}
+	switch d.mode {
+	case DeleteHard, DeleteIgnore:
+		return d, nil
+	case DeleteSoft:
+	default:
+		return d, fmt.Errorf("%s must be %s, %s or %s", DeleteMode, DeleteHard, DeleteSoft, DeleteIgnore)
+	}
+	d.column, _ = definition.SourceConfig[DeletedColumn].(string)
+	d.atColumn, _ = definition.SourceConfig[DeletedAtColumn].(string)
+	if d.column == "" && definition.IncomingMappingConfig != nil {
+		for _, pm := range definition.IncomingMappingConfig.PropertyMappings {
+			if pm.IsDeleted {
+				d.column = pm.Property
+				break
+			}
+		}
+	}
+	if d.column == "" {
+		return d, fmt.Errorf("%s %s requires %s or a deleted property in the incoming mapping", DeleteMode, DeleteSoft, DeletedColumn)
+	}
+	return d, nil
+}
+
+// columns returns the soft delete columns that are not already in cols
+func (d deletion) columns(cols []string) []string {
+	var extra []string
+	for _, col := range []string{d.column, d.atColumn} {
+		if col != "" && !containsColumn(cols, col) {
+			extra = append(extra, col)
+		}
+	}
+	return extra
+}
+
+// schemaMappings adds mappings for the soft delete columns that are not mapped, so that
+// create_table and evolve_schema create them with the table
+func (d deletion) schemaMappings(mappings []*common.EntityToItemPropertyMapping) []*common.EntityToItemPropertyMapping {
+	var mapped []string
+	for _, pm := range mappings {
+		mapped = append(mapped, pm.Property)
+	}
+	extra := d.columns(mapped)
+	if len(extra) == 0 {
+		return mappings
+	}
+	result := append([]*common.EntityToItemPropertyMapping{}, mappings...)
+	for _, col := range extra {
+		if col == d.column {
+			result = append(result, &common.EntityToItemPropertyMapping{Property: col, IsDeleted: true})
+		} else {
+			result = append(result, &common.EntityToItemPropertyMapping{Property: col, Datatype: "timestamp"})
+		}
+	}
+	return result
+}
+
+// setColumn sets the value of a column in a row, replacing the value of a mapped column with the same name
+func setColumn(item *RowItem, col string, value any) {
+	for _, c := range item.Columns {
+		if strings.EqualFold(c, col) {
+			item.Map[c] = value
+			return
+		}
+	}
+	item.SetValue(col, value)
+}
+
+// flagLiteral returns the sql literal for true in a flag column of the given type
+func flagLiteral(col tableColumn) string {
+	switch col.DataType {
+	case "NUMBER", "FLOAT", "BINARY_FLOAT", "BINARY_DOUBLE":
+		return "1"
+	case "BOOLEAN":
+		return "TRUE"
+	default:
+		return "'true'"
+	}
+}
+
+// softDeleteStatement flags all rows of the target table whose key is missing in the staging table.
+// rows that are already flagged keep their deletion timestamp
+func softDeleteStatement(target, staging string, keyCols []string, d deletion) string {
+	col := "t." + quoteIdent(d.column)
+	set := col + " = " + d.flag
+	if d.atColumn != "" {
+		set += ", t." + quoteIdent(d.atColumn) + " = SYSTIMESTAMP"
+	}
+	return "UPDATE " + quoteTable(target) + " t SET " + set +
+		" WHERE NOT EXISTS (SELECT 1 FROM " + quoteTable(staging) + " n WHERE " + keyCondition(keyCols) + ")" +
+		" AND (" + col + " IS NULL OR " + col + " <> " + d.flag + ")"
+}
+
+// flagSet tells if a scanned deleted flag is set
+func flagSet(v any) bool {
+	switch val := v.(type) {
+	case *sql.NullBool:
+		return val.Valid && val.Bool
+	case *sql.NullString:
+		return val.Valid && (strings.EqualFold(val.String, "true") || val.String == "1")
+	case *sql.NullFloat64:
+		return val.Valid && val.Float64 != 0
+	case columnValue:
+		switch n := val.value().(type) {
+		case int64:
+			return n != 0
+		case float64:
+			return n != 0
+		case string:
+			return strings.EqualFold(n, "true") || n == "1"
+		}
+	}
+	return false
+}
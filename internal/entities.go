@@ -0,0 +1,254 @@
+package layer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	common "github.com/mimiro-io/common-datalayer"
+)
+
+// newEntitiesIterator reads the current content of the dataset table, ordered by the key columns.
+// pages are continued by key (keyset pagination), the token contains the key of the last emitted row.
+// rows flagged as deleted (in the outgoing mapping or by soft delete) are left out.
+func (d *Dataset) newEntitiesIterator(mapper *common.Mapper, from string, limit int) (common.EntityIterator, common.LayerError) {
+	keys, lerr := keyColumns(d.datasetDefinition)
+	if lerr != nil {
+		return nil, lerr
+	}
+	deletedCol := deletedColumn(d.datasetDefinition)
+	query, args, err := buildEntitiesQuery(d.datasetDefinition, keys, from, limit)
+	if err != nil {
+		d.logger.Error("failed to build query", "error", err)
+		return nil, ErrQuery(err)
+	}
+	d.logger.Debug(fmt.Sprintf("entities query for dataset %s: %s", d.Name(), query), "dataset", d.Name())
+
+	ctx := context.Background()
+	tx, lerr := d.beginReadOnly(ctx)
+	if lerr != nil {
+		return nil, lerr
+	}
+	args = append(queryArgs(d.datasetDefinition, nil, limit), args...)
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		tx.Rollback()
+		d.logger.Error("failed to execute query", "error", err)
+		return nil, ErrQuery(err)
+	}
+	cts, columns, rowBuf, err := d.scanBuffer(rows)
+	if err != nil {
+		rows.Close()
+		tx.Rollback()
+		return nil, ErrQuery(err)
+	}
+
+	it := &dbIterator{
+		logger:       d.logger,
+		since:        from,
+		limit:        limit,
+		mapper:       mapper,
+		tx:           tx,
+		rows:         rows,
+		currentToken: from,
+		colTypes:     cts,
+		columns:      columns,
+		rowBuf:       rowBuf,
+		deleted:      -1,
+	}
+	for _, k := range keys {
+		i := columnIndex(columns, k)
+		if i < 0 {
+			it.Close()
+			return nil, ErrQuery(fmt.Errorf("key column %s not found in dataset %s", k, d.Name()))
+		}
+		it.keys = append(it.keys, i)
+	}
+	if deletedCol != "" {
+		it.deleted = columnIndex(columns, deletedCol)
+	}
+	return it, nil
+}
+
+// buildEntitiesQuery selects the rows after the key in the from token, ordered by key.
+// deleted rows are filtered in the query, so that the limit can be applied by the database
+func buildEntitiesQuery(definition *common.DatasetDefinition, keys []string, from string, limit int) (string, []any, error) {
+	if definition.OutgoingMappingConfig == nil {
+		return "", nil, fmt.Errorf("outgoing mapping config is missing")
+	}
+	source, table := querySource(definition)
+	cols := "*"
+	if !definition.OutgoingMappingConfig.MapAll {
+		var selected []string
+		for _, pm := range definition.OutgoingMappingConfig.PropertyMappings {
+			selected = append(selected, pm.Property)
+		}
+		// the keys are needed for the token, and the deleted flag to skip deleted rows, even if they are not mapped
+		for _, k := range append(keys, deletedColumn(definition)) {
+			if k != "" && !containsColumn(selected, k) {
+				selected = append(selected, k)
+			}
+		}
+		cols = strings.Join(selected, ", ")
+	}
+	q := "SELECT " + cols + " FROM " + source
+	var where []string
+	if isChangeLog(definition) {
+		// the current state of a change log is the latest version of each key
+		latest, err := latestQuery(definition, source, table, "")
+		if err != nil {
+			return "", nil, err
+		}
+		q = "SELECT " + cols + " FROM (" + latest + ") " + table
+		where = append(where, latestColumn+" = 1")
+	}
+
+	if col := deletedColumn(definition); col != "" {
+		// the flag can be a NUMBER, BOOLEAN or text column, see flagSet
+		where = append(where, fmt.Sprintf("(%s.%s IS NULL OR LOWER(TO_CHAR(%s.%s)) NOT IN ('1', 'true'))", table, col, table, col))
+	}
+
+	var args []any
+	if from != "" {
+		last, err := decodeKeyToken(from, len(keys))
+		if err != nil {
+			return "", nil, err
+		}
+		// (k1, k2) > (v1, v2) is not supported by oracle, so it is expanded to
+		// k1 > v1 OR (k1 = v1 AND k2 > v2). the binds are named, so that they can be combined
+		// with the named placeholders of a custom query
+		var or []string
+		for i := range keys {
+			var and []string
+			for j := 0; j < i; j++ {
+				args = append(args, sql.Named(fmt.Sprintf("k%d", len(args)+1), last[j]))
+				and = append(and, fmt.Sprintf("%s.%s = :k%d", table, keys[j], len(args)))
+			}
+			args = append(args, sql.Named(fmt.Sprintf("k%d", len(args)+1), last[i]))
+			and = append(and, fmt.Sprintf("%s.%s > :k%d", table, keys[i], len(args)))
+			or = append(or, "("+strings.Join(and, " AND ")+")")
+		}
+		where = append(where, "("+strings.Join(or, " OR ")+")")
+	}
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var order []string
+	for _, k := range keys {
+		order = append(order, table+"."+k)
+	}
+	q += " ORDER BY " + strings.Join(order, ", ")
+	if limit != 0 {
+		q += " FETCH FIRST " + strconv.Itoa(limit) + " ROWS ONLY"
+	}
+	return q, args, nil
+}
+
+// encodeKeyToken encodes the key of the last emitted row as continuation token.
+// text and numbers are encoded as strings, times and bytes as objects, so that they are bound
+// with their type when the token is decoded
+func encodeKeyToken(key []any) string {
+	parts := make([]any, len(key))
+	for i, v := range key {
+		switch v := v.(type) {
+		case time.Time:
+			parts[i] = map[string]string{"time": v.Format(time.RFC3339Nano)}
+		case []byte:
+			parts[i] = map[string]string{"raw": base64.StdEncoding.EncodeToString(v)}
+		default:
+			parts[i] = v
+		}
+	}
+	b, _ := json.Marshal(parts)
+	return encodeToken(string(b), 0)
+}
+
+func decodeKeyToken(token string, size int) ([]any, error) {
+	val, _, err := decodeToken(token)
+	if err != nil {
+		return nil, err
+	}
+	var parts []json.RawMessage
+	if err = json.Unmarshal([]byte(val), &parts); err != nil || len(parts) != size {
+		return nil, fmt.Errorf("invalid entities token %s", token)
+	}
+	key := make([]any, len(parts))
+	for i, p := range parts {
+		var s string
+		if json.Unmarshal(p, &s) == nil {
+			key[i] = s
+			continue
+		}
+		var typed map[string]string
+		if err = json.Unmarshal(p, &typed); err != nil {
+			return nil, fmt.Errorf("invalid entities token %s", token)
+		}
+		if t, ok := typed["time"]; ok {
+			key[i], err = time.Parse(time.RFC3339Nano, t)
+		} else if r, ok := typed["raw"]; ok {
+			key[i], err = base64.StdEncoding.DecodeString(r)
+		} else {
+			err = fmt.Errorf("unknown key type")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid entities token %s. %w", token, err)
+		}
+	}
+	return key, nil
+}
+
+// keyValue returns the scanned value as bind value for the next page. text, numbers and booleans
+// are returned as string, so that they can be compared with both text and number columns
+func keyValue(v any) any {
+	switch v := v.(type) {
+	case *numberValue:
+		return v.String
+	case *sql.NullString:
+		return v.String
+	case *sql.NullFloat64:
+		return strconv.FormatFloat(v.Float64, 'f', -1, 64)
+	case *sql.NullBool:
+		if v.Bool {
+			return "1"
+		}
+		return "0"
+	case *timeValue:
+		return v.Time
+	case *bytesValue:
+		return append([]byte(nil), v.b...)
+	case columnValue:
+		return fmt.Sprintf("%v", v.value())
+	}
+	return ""
+}
+
+// deletedColumn returns the flag column of soft deleted rows, or the column mapped as deleted flag
+// in the outgoing mapping, if any
+func deletedColumn(definition *common.DatasetDefinition) string {
+	if d, err := deletionOf(definition); err == nil && d.mode == DeleteSoft {
+		return d.column
+	}
+	if definition.OutgoingMappingConfig != nil {
+		for _, pm := range definition.OutgoingMappingConfig.PropertyMappings {
+			if pm.IsDeleted {
+				return pm.Property
+			}
+		}
+	}
+	return ""
+}
+
+func columnIndex(columns []string, col string) int {
+	for i, c := range columns {
+		if strings.EqualFold(c, col) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,100 @@
+package layer
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildEntitiesQuery(t *testing.T) {
+	t.Run("should order first page by key", func(t *testing.T) {
+		def := testReadDefinition(map[string]any{TableName: "sample"})
+		q, args, err := buildEntitiesQuery(def, []string{"ID"}, "", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q != "SELECT ID, NAME FROM sample ORDER BY sample.ID FETCH FIRST 10 ROWS ONLY" || len(args) != 0 {
+			t.Fatalf("unexpected query: %s, %v", q, args)
+		}
+	})
+	t.Run("should continue after last composite key", func(t *testing.T) {
+		def := testReadDefinition(map[string]any{TableName: "sample"})
+		token := encodeKeyToken([]any{"a", "7"})
+		q, args, err := buildEntitiesQuery(def, []string{"TENANT", "ID"}, token, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "SELECT ID, NAME, TENANT FROM sample WHERE ((sample.TENANT > :k1) OR " +
+			"(sample.TENANT = :k2 AND sample.ID > :k3)) ORDER BY sample.TENANT, sample.ID FETCH FIRST 10 ROWS ONLY"
+		if q != expected {
+			t.Fatalf("unexpected query: %s", q)
+		}
+		if !reflect.DeepEqual(args, []any{sql.Named("k1", "a"), sql.Named("k2", "a"), sql.Named("k3", "7")}) {
+			t.Fatalf("unexpected args: %v", args)
+		}
+	})
+	t.Run("should reject token of other key", func(t *testing.T) {
+		def := testReadDefinition(map[string]any{TableName: "sample"})
+		if _, _, err := buildEntitiesQuery(def, []string{"ID"}, encodeKeyToken([]any{"a", "7"}), 10); err == nil {
+			t.Fatal("expected error for token with wrong key size")
+		}
+		if _, _, err := buildEntitiesQuery(def, []string{"ID"}, encodeToken("164565574", 0), 10); err == nil {
+			t.Fatal("expected error for changes token")
+		}
+	})
+	t.Run("should read latest versions of change log", func(t *testing.T) {
+		def := testReadDefinition(map[string]any{TableName: "sample", ChangeLog: true, RecordedColumn: "RECORDED"})
+		q, args, err := buildEntitiesQuery(def, []string{"ID"}, encodeKeyToken([]any{"7"}), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "SELECT ID, NAME FROM (SELECT sample.*, ROW_NUMBER() OVER (PARTITION BY sample.ID " +
+			"ORDER BY sample.RECORDED DESC) AS \"_LATEST\" FROM sample) sample " +
+			"WHERE \"_LATEST\" = 1 AND ((sample.ID > :k1)) ORDER BY sample.ID FETCH FIRST 10 ROWS ONLY"
+		if q != expected || !reflect.DeepEqual(args, []any{sql.Named("k1", "7")}) {
+			t.Fatalf("unexpected query: %s, %v", q, args)
+		}
+	})
+	t.Run("should filter deleted rows and keep the limit", func(t *testing.T) {
+		def := testReadDefinition(map[string]any{TableName: "sample", DeleteMode: DeleteSoft, DeletedColumn: "REMOVED"})
+		q, _, err := buildEntitiesQuery(def, []string{"ID"}, "", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "SELECT ID, NAME, REMOVED FROM sample WHERE (sample.REMOVED IS NULL OR " +
+			"LOWER(TO_CHAR(sample.REMOVED)) NOT IN ('1', 'true')) ORDER BY sample.ID FETCH FIRST 10 ROWS ONLY"
+		if q != expected {
+			t.Fatalf("unexpected query: %s", q)
+		}
+	})
+	t.Run("should bind time and raw keys with their type", func(t *testing.T) {
+		created := time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.FixedZone("", 3600))
+		for _, target := range []any{
+			&timeValue{NullTime: sql.NullTime{Time: created, Valid: true}},
+			&bytesValue{b: []byte{0xca, 0xfe}, valid: true},
+			&numberValue{NullString: sql.NullString{String: "7", Valid: true}},
+		} {
+			key, err := decodeKeyToken(encodeKeyToken([]any{keyValue(target)}), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch v := key[0].(type) {
+			case time.Time:
+				if !v.Equal(created) {
+					t.Fatalf("expected %v, got %v", created, v)
+				}
+			case []byte:
+				if !reflect.DeepEqual(v, []byte{0xca, 0xfe}) {
+					t.Fatalf("unexpected raw key %v", v)
+				}
+			case string:
+				if v != "7" {
+					t.Fatalf("unexpected number key %s", v)
+				}
+			default:
+				t.Fatalf("unexpected key %#v for %T", v, target)
+			}
+		}
+	})
+}
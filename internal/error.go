@@ -20,6 +20,21 @@ var (
 	ErrBatchSizeMismatch = func(observed, expected int) common.LayerError {
 		return common.Errorf(common.LayerErrorInternal, "batch size mismatch. rows affected: %d, expected: %d", observed, expected)
 	}
+	ErrFullSyncNotActive = func(syncID, datasetName string) common.LayerError {
+		return common.Errorf(common.LayerErrorBadParameter, "full sync %s is not active for dataset %s", syncID, datasetName)
+	}
+	ErrInvalidConfig = func(err error) common.LayerError {
+		return common.Errorf(common.LayerErrorBadParameter, "invalid configuration. %w", err)
+	}
+	ErrConversion = func(entityID, column string, err error) common.LayerError {
+		return common.Errorf(common.LayerErrorBadParameter, "cannot convert value of column %s in entity %s. %w", column, entityID, err)
+	}
+	ErrSchema = func(datasetName string, err error) common.LayerError {
+		return common.Errorf(common.LayerErrorInternal, "failed to update table schema of dataset %s. %w", datasetName, err)
+	}
+	ErrWriterFailed = func(err error) common.LayerError {
+		return common.Errorf(common.LayerErrorInternal, "writer stopped after a failed write, no further entities are accepted. %w", err)
+	}
 	ErrGeneric = func(msg string, extra ...any) common.LayerError {
 		return common.Errorf(common.LayerErrorInternal, fmt.Sprintf(msg, extra...))
 	}
@@ -0,0 +1,188 @@
+package layer
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	common "github.com/mimiro-io/common-datalayer"
+)
+
+// fullSync keeps track of the target table of a writer that appends to a full sync staging table
+type fullSync struct {
+	dataset    *Dataset
+	syncID     string
+	target     string
+	targetMode bool // append mode of the target table
+	deletion   deletion
+	lastBatch  bool
+}
+
+func stagingTable(definition *common.DatasetDefinition) string {
+	if t, ok := definition.SourceConfig[FullSyncTable].(string); ok && t != "" {
+		return t
+	}
+	return definition.SourceConfig[TableName].(string) + "_staging"
+}
+
+// columns of the staging table in upsert mode, in addition to the columns of the target table.
+// the staging table can hold several versions of a key from different batches of a sync, the
+// sequence orders them by write. deleted versions are flagged.
+const (
+	stagingSeq     = "_SEQ"
+	stagingDeleted = "_DELETED"
+)
+
+// prepareStaging (re)creates an empty staging table with the same columns as the target table.
+// leftovers from abandoned syncs are dropped in the process.
+// If upsert is set, the sequence and deleted columns are added for stagingDedupeStatements
+func prepareStaging(ctx context.Context, db *sql.DB, target, staging string, upsert bool) error {
+	_, err := db.ExecContext(ctx, "DROP TABLE "+quoteTable(staging))
+	if err != nil && !strings.Contains(err.Error(), "ORA-00942") { // table or view does not exist
+		return err
+	}
+	_, err = db.ExecContext(ctx, "CREATE TABLE "+quoteTable(staging)+" AS SELECT * FROM "+quoteTable(target)+" WHERE 1=0")
+	if err != nil || !upsert {
+		return err
+	}
+	_, err = db.ExecContext(ctx, "ALTER TABLE "+quoteTable(staging)+" ADD ("+quoteIdent(stagingSeq)+
+		" NUMBER GENERATED ALWAYS AS IDENTITY, "+quoteIdent(stagingDeleted)+" VARCHAR2(5))")
+	return err
+}
+
+// stagingDedupeStatements reduce the staging table of an upsert sync to the last written version of
+// each key, and remove the keys whose last version is a deletion.
+func stagingDedupeStatements(staging string, keyCols []string) []string {
+	s := quoteTable(staging)
+	keys := make([]string, len(keyCols))
+	for i, k := range keyCols {
+		keys[i] = quoteIdent(k)
+	}
+	return []string{
+		"DELETE FROM " + s + " WHERE ROWID IN (SELECT rid FROM (SELECT ROWID AS rid, ROW_NUMBER() OVER (PARTITION BY " +
+			strings.Join(keys, ", ") + " ORDER BY " + quoteIdent(stagingSeq) + " DESC) AS rn FROM " + s + ") WHERE rn > 1)",
+		"DELETE FROM " + s + " WHERE " + quoteIdent(stagingDeleted) + " = 'true'",
+	}
+}
+
+// fullSyncStatements returns the statements needed to replace the content of target with
+// the content of staging.
+// In append mode, the target table is simply replaced. Otherwise rows are merged by key: rows
+// in staging update the matching rows or are inserted, and rows missing in staging are deleted.
+// With delete mode soft, rows missing in staging are flagged instead, with delete mode ignore they are kept.
+// In append mode, only the rows of the keys in staging are replaced then.
+func fullSyncStatements(target, staging string, keyCols []string, columns []string, appendMode bool, d deletion) []string {
+	t := quoteTable(target)
+	s := quoteTable(staging)
+	missing := "DELETE FROM " + t + " t WHERE NOT EXISTS (SELECT 1 FROM " + s + " n WHERE " + keyCondition(keyCols) + ")"
+	if d.mode == DeleteSoft {
+		missing = softDeleteStatement(target, staging, keyCols, d)
+	}
+	if appendMode {
+		if d.mode == DeleteHard {
+			return []string{
+				"DELETE FROM " + t,
+				"INSERT INTO " + t + " SELECT * FROM " + s,
+			}
+		}
+		stmts := []string{
+			"DELETE FROM " + t + " t WHERE EXISTS (SELECT 1 FROM " + s + " n WHERE " + keyCondition(keyCols) + ")",
+			"INSERT INTO " + t + " SELECT * FROM " + s,
+		}
+		if d.mode == DeleteSoft {
+			stmts = append(stmts, missing)
+		}
+		return stmts
+	}
+	var sb strings.Builder
+	sb.WriteString("MERGE INTO " + t + " t USING " + s + " n ON (" + keyCondition(keyCols) + ")")
+	needComma := false
+	for _, col := range columns {
+		if containsColumn(keyCols, col) {
+			continue
+		}
+		if needComma {
+			sb.WriteString(", ")
+		} else {
+			sb.WriteString("\nWHEN MATCHED THEN UPDATE SET ")
+		}
+		sb.WriteString("t." + quoteIdent(col) + " = n." + quoteIdent(col))
+		needComma = true
+	}
+	sb.WriteString("\nWHEN NOT MATCHED THEN INSERT (")
+	for i, col := range columns {
+		if i != 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(quoteIdent(col))
+	}
+	sb.WriteString(") VALUES (")
+	for i, col := range columns {
+		if i != 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("n." + quoteIdent(col))
+	}
+	sb.WriteString(")")
+	switch d.mode {
+	case DeleteHard:
+		return []string{missing, sb.String()}
+	case DeleteSoft:
+		return []string{sb.String(), missing}
+	default:
+		return []string{sb.String()}
+	}
+}
+
+// complete applies the staging table to the target table in one transaction and drops the staging table.
+func (f *fullSync) complete(ctx context.Context, db *sql.DB, staging string, keyCols []string) error {
+	f.dataset.fullSyncLock.Lock()
+	defer f.dataset.fullSyncLock.Unlock()
+	if f.dataset.fullSyncID != f.syncID {
+		return ErrFullSyncNotActive(f.syncID, f.dataset.Name())
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	columns := f.dataset.incomingColumns()
+	columns = append(columns, f.deletion.columns(columns)...)
+	var stmts []string
+	if !f.targetMode {
+		stmts = stagingDedupeStatements(staging, keyCols)
+	}
+	stmts = append(stmts, fullSyncStatements(f.target, staging, keyCols, columns, f.targetMode, f.deletion)...)
+	for _, stmt := range stmts {
+		f.dataset.logger.Debug(stmt)
+		_, err = tx.ExecContext(ctx, stmt)
+		if err != nil {
+			if err2 := tx.Rollback(); err2 != nil {
+				f.dataset.logger.Error("Failed to rollback full sync transaction")
+			}
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	f.dataset.fullSyncID = ""
+	f.dataset.logger.Info("full sync " + f.syncID + " completed for dataset " + f.dataset.Name())
+
+	_, err = db.ExecContext(ctx, "DROP TABLE "+quoteTable(staging))
+	if err != nil {
+		// the sync itself succeeded. the staging table will be recreated by the next sync
+		f.dataset.logger.Warn("failed to drop staging table", "table", staging, "error", err)
+	}
+	return nil
+}
+
+// incomingColumns lists the table columns written by the incoming mapping
+func (d *Dataset) incomingColumns() []string {
+	var cols []string
+	for _, pm := range d.datasetDefinition.IncomingMappingConfig.PropertyMappings {
+		cols = append(cols, pm.Property)
+	}
+	return cols
+}
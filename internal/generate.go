@@ -0,0 +1,162 @@
+package layer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	common "github.com/mimiro-io/common-datalayer"
+)
+
+// LoadConfig reads and merges the json files in a config folder, like the service runner does,
+// and applies the environment overrides of the system config.
+func LoadConfig(folder string) (*common.Config, error) {
+	files, err := os.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+	config := &common.Config{NativeSystemConfig: common.NativeSystemConfig{}}
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(folder, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var part common.Config
+		if err = json.Unmarshal(b, &part); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file.Name(), err)
+		}
+		if part.NativeSystemConfig != nil {
+			config.NativeSystemConfig = part.NativeSystemConfig
+		}
+		if part.LayerServiceConfig != nil {
+			config.LayerServiceConfig = part.LayerServiceConfig
+		}
+		config.DatasetDefinitions = append(config.DatasetDefinitions, part.DatasetDefinitions...)
+	}
+	return config, EnvOverrides(config)
+}
+
+// GenerateDatasetDefinition connects to the database of the system config and creates a dataset
+// definition with incoming and outgoing mappings for all columns of a table or view.
+// Notes about choices the generator could not make are returned with the definition.
+func GenerateDatasetDefinition(ctx context.Context, config *common.Config, table, datasetName, baseURI string) (*common.DatasetDefinition, []string, error) {
+	db, err := newOracleDB(config, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer db.close()
+	if err = db.healthy(); err != nil {
+		return nil, nil, err
+	}
+	schema, err := describeTable(ctx, db.pool, table)
+	if err != nil {
+		return nil, nil, err
+	}
+	if schema.PrimaryKey == nil {
+		// views and tables without primary key can still have a unique index
+		for _, idx := range schema.Indexes {
+			if idx.Unique {
+				schema.PrimaryKey = idx.Columns
+				break
+			}
+		}
+	}
+	def, notes := datasetDefinition(schema, table, datasetName, baseURI)
+	return def, notes, nil
+}
+
+// sinceColumnPattern matches names of columns that are likely updated with every change of a row
+var sinceColumnPattern = regexp.MustCompile(`(?i)(MODIFIED|UPDATED|CHANGED|LAST_?CHANGE|RECORDED)`)
+
+// datasetDefinition builds the dataset definition for a described table
+func datasetDefinition(schema *tableSchema, table, datasetName, baseURI string) (*common.DatasetDefinition, []string) {
+	if datasetName == "" {
+		datasetName = strings.ToLower(schema.Table)
+	}
+	if baseURI == "" {
+		baseURI = "http://data.example.io/" + datasetName + "/"
+	}
+	var notes []string
+	def := &common.DatasetDefinition{
+		DatasetName:           datasetName,
+		SourceConfig:          map[string]any{TableName: table},
+		IncomingMappingConfig: &common.IncomingMappingConfig{BaseURI: baseURI},
+		OutgoingMappingConfig: &common.OutgoingMappingConfig{BaseURI: baseURI},
+	}
+
+	identity := ""
+	switch {
+	case len(schema.PrimaryKey) == 1:
+		identity = schema.PrimaryKey[0]
+	case len(schema.PrimaryKey) > 1:
+		identity = schema.PrimaryKey[0]
+		var keys []any
+		for _, k := range schema.PrimaryKey {
+			keys = append(keys, k)
+		}
+		def.SourceConfig[KeyColumns] = keys
+		notes = append(notes, fmt.Sprintf("composite key %s, %s is used as entity id. consider a custom query that concatenates the key",
+			strings.Join(schema.PrimaryKey, ", "), identity))
+	default:
+		for _, c := range schema.Columns {
+			if strings.EqualFold(c.Name, "ID") {
+				identity = c.Name
+			}
+		}
+		if identity == "" {
+			notes = append(notes, "no primary key or unique index found, an identity mapping must be added")
+		} else {
+			notes = append(notes, fmt.Sprintf("no primary key or unique index found, %s is used as entity id", identity))
+		}
+	}
+
+	sinceColumn := ""
+	for _, c := range schema.Columns {
+		entityProperty := strings.ToLower(c.Name)
+		hint := datatypeHint(c)
+		if c.Name == identity {
+			def.IncomingMappingConfig.PropertyMappings = append(def.IncomingMappingConfig.PropertyMappings,
+				&common.EntityToItemPropertyMapping{Property: c.Name, IsIdentity: true, StripReferencePrefix: true})
+			def.OutgoingMappingConfig.PropertyMappings = append(def.OutgoingMappingConfig.PropertyMappings,
+				&common.ItemToEntityPropertyMapping{Property: c.Name, IsIdentity: true, URIValuePattern: baseURI + "{value}"})
+			continue
+		}
+		def.IncomingMappingConfig.PropertyMappings = append(def.IncomingMappingConfig.PropertyMappings,
+			&common.EntityToItemPropertyMapping{EntityProperty: entityProperty, Property: c.Name, Datatype: hint})
+		def.OutgoingMappingConfig.PropertyMappings = append(def.OutgoingMappingConfig.PropertyMappings,
+			&common.ItemToEntityPropertyMapping{EntityProperty: entityProperty, Property: c.Name, Datatype: hint})
+		if sinceColumn == "" && (c.DataType == "DATE" || strings.HasPrefix(c.DataType, "TIMESTAMP")) &&
+			sinceColumnPattern.MatchString(c.Name) {
+			sinceColumn = c.Name
+		}
+	}
+	if sinceColumn != "" {
+		def.SourceConfig[SinceColumn] = sinceColumn
+	} else {
+		notes = append(notes, "no since column found, consider change_tracking ora_rowscn for incremental reads")
+	}
+	return def, notes
+}
+
+// datatypeHint returns the mapping datatype of a column. only types that would otherwise
+// be emitted in a different json type get a hint
+func datatypeHint(c schemaColumn) string {
+	switch c.DataType {
+	case "NUMBER":
+		if c.Scale != nil && *c.Scale == 0 && c.Precision != nil && *c.Precision <= 18 {
+			return "long"
+		}
+	case "FLOAT", "BINARY_FLOAT", "BINARY_DOUBLE":
+		return "double"
+	case "BOOLEAN":
+		return "bool"
+	}
+	return ""
+}
@@ -0,0 +1,56 @@
+package layer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDatasetDefinition(t *testing.T) {
+	p := func(i int64) *int64 { return &i }
+	schema := &tableSchema{
+		Table: "PEOPLE",
+		Columns: []schemaColumn{
+			{Name: "ID", DataType: "NUMBER", Precision: p(10), Scale: p(0)},
+			{Name: "NAME", DataType: "VARCHAR2", Length: 100},
+			{Name: "WEIGHT", DataType: "BINARY_DOUBLE"},
+			{Name: "AMOUNT", DataType: "NUMBER", Precision: p(30), Scale: p(2)},
+			{Name: "CREATED", DataType: "DATE"},
+			{Name: "LAST_MODIFIED", DataType: "TIMESTAMP(6)"},
+		},
+		PrimaryKey: []string{"ID"},
+	}
+
+	def, notes := datasetDefinition(schema, "app.people", "", "http://data.test/people/")
+	if def.DatasetName != "people" || len(notes) != 0 {
+		t.Fatalf("unexpected definition: %+v, notes: %v", def, notes)
+	}
+	if !reflect.DeepEqual(def.SourceConfig, map[string]any{TableName: "app.people", SinceColumn: "LAST_MODIFIED"}) {
+		t.Fatalf("unexpected source config: %v", def.SourceConfig)
+	}
+	out := def.OutgoingMappingConfig.PropertyMappings
+	if len(out) != 6 || !out[0].IsIdentity || out[0].URIValuePattern != "http://data.test/people/{value}" {
+		t.Fatalf("unexpected identity mapping: %+v", out[0])
+	}
+	var hints []string
+	for _, pm := range out[1:] {
+		hints = append(hints, pm.EntityProperty+":"+pm.Datatype)
+	}
+	if !reflect.DeepEqual(hints, []string{"name:", "weight:double", "amount:", "created:", "last_modified:"}) {
+		t.Fatalf("unexpected outgoing mappings: %v", hints)
+	}
+	in := def.IncomingMappingConfig.PropertyMappings
+	if len(in) != 6 || !in[0].IsIdentity || !in[0].StripReferencePrefix || in[1].Property != "NAME" {
+		t.Fatalf("unexpected incoming mappings: %+v", in)
+	}
+
+	t.Run("should declare composite keys", func(t *testing.T) {
+		schema.PrimaryKey = []string{"NAME", "CREATED"}
+		def, notes := datasetDefinition(schema, "people", "persons", "")
+		if def.DatasetName != "persons" || def.IncomingMappingConfig.BaseURI != "http://data.example.io/persons/" {
+			t.Fatalf("unexpected definition: %+v", def)
+		}
+		if !reflect.DeepEqual(def.SourceConfig[KeyColumns], []any{"NAME", "CREATED"}) || len(notes) != 1 {
+			t.Fatalf("unexpected key columns: %v, notes: %v", def.SourceConfig[KeyColumns], notes)
+		}
+	})
+}
@@ -2,36 +2,113 @@ package layer
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sort"
+	"sync"
+	"time"
 
 	common "github.com/mimiro-io/common-datalayer"
 )
 
 type OracleDatalayer struct {
-	db       *oracleDB
-	datasets map[string]*Dataset
-	config   *common.Config
-	logger   common.Logger
-	metrics  common.Metrics
+	db          *oracleDB            // default connection
+	connections map[string]*oracleDB // named connections in system_config
+	datasets    map[string]*Dataset
+	config      *common.Config
+	logger      common.Logger
+	metrics     common.Metrics
 }
 
 type Dataset struct {
 	logger            common.Logger
 	db                *oracleDB
 	datasetDefinition *common.DatasetDefinition
+	fullSyncLock      sync.Mutex
+	fullSyncID        string
+	// the described table schema is cached for the metadata, see describedSchema
+	schemaLock sync.Mutex
+	schema     *tableSchema
+	schemaErr  error
+	schemaAt   time.Time
 }
 
+// schemaCacheTTL is how long a table description is reused in the dataset metadata
+const schemaCacheTTL = 5 * time.Minute
+
+// MetaData returns the source config of the dataset. For table datasets, the columns, keys and indexes
+// of the table are added as "schema", together with the differences between table and mappings.
 func (d *Dataset) MetaData() map[string]any {
-	return d.datasetDefinition.SourceConfig
+	md := map[string]any{}
+	for k, v := range d.datasetDefinition.SourceConfig {
+		md[k] = v
+	}
+	table, ok := d.datasetDefinition.SourceConfig[TableName].(string)
+	if !ok || d.db == nil {
+		return md
+	}
+	if err := d.db.healthy(); err != nil {
+		md["connection_error"] = err.Error()
+		return md
+	}
+	schema, err := d.describedSchema(table)
+	if err != nil {
+		md["schema_error"] = err.Error()
+		return md
+	}
+	md["schema"] = schema
+	return md
+}
+
+// describedSchema returns the description of the dataset table, compared with the mappings.
+// descriptions are cached for schemaCacheTTL, so that listing the datasets does not query the
+// data dictionary for every dataset each time
+func (d *Dataset) describedSchema(table string) (*tableSchema, error) {
+	d.schemaLock.Lock()
+	defer d.schemaLock.Unlock()
+	if !d.schemaAt.IsZero() && time.Since(d.schemaAt) < schemaCacheTTL {
+		return d.schema, d.schemaErr
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	d.schema, d.schemaErr = describeTable(ctx, d.db.pool, table)
+	if d.schemaErr != nil {
+		d.logger.Warn("failed to describe table", "dataset", d.Name(), "error", d.schemaErr)
+	} else {
+		d.schema.compareMappings(d.datasetDefinition)
+	}
+	d.schemaAt = time.Now()
+	return d.schema, d.schemaErr
+}
+
+// invalidateSchema drops the cached table description, after the table or the mappings changed
+func (d *Dataset) invalidateSchema() {
+	d.schemaLock.Lock()
+	defer d.schemaLock.Unlock()
+	d.schema, d.schemaErr, d.schemaAt = nil, nil, time.Time{}
 }
 
 func (d *Dataset) Name() string {
 	return d.datasetDefinition.DatasetName
 }
 
+// connection returns the connection of a dataset definition
+func (dl *OracleDatalayer) connection(definition *common.DatasetDefinition) *oracleDB {
+	if name := connectionName(definition); name != "" {
+		return dl.connections[name]
+	}
+	return dl.db
+}
+
 func (dl *OracleDatalayer) Stop(ctx context.Context) error {
-	// nothing to do for now
-	return nil
+	var errs []error
+	for _, db := range dl.connections {
+		errs = append(errs, db.close())
+	}
+	if dl.db != nil {
+		errs = append(errs, dl.db.close())
+	}
+	return errors.Join(errs...)
 }
 
 func (dl *OracleDatalayer) Dataset(dataset string) (common.Dataset, common.LayerError) {
@@ -42,10 +119,28 @@ func (dl *OracleDatalayer) Dataset(dataset string) (common.Dataset, common.Layer
 	return nil, ErrDatasetNotFound(dataset)
 }
 
+// Health returns an error for each database that is not reachable.
+// common-datalayer has no hook for layer health checks yet, so /health does not use it. It is kept
+// ready for that hook, and used by the tests
+func (dl *OracleDatalayer) Health() error {
+	var errs []error
+	if dl.db != nil {
+		if err := dl.db.healthy(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for name, db := range dl.connections {
+		if err := db.healthy(); err != nil {
+			errs = append(errs, fmt.Errorf("connection %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (dl *OracleDatalayer) DatasetDescriptions() []*common.DatasetDescription {
 	var datasetDescriptions []*common.DatasetDescription
-	for key := range dl.datasets {
-		datasetDescriptions = append(datasetDescriptions, &common.DatasetDescription{Name: key})
+	for key, ds := range dl.datasets {
+		datasetDescriptions = append(datasetDescriptions, &common.DatasetDescription{Name: key, Metadata: ds.MetaData()})
 	}
 	sort.Slice(datasetDescriptions, func(i, j int) bool {
 		return datasetDescriptions[i].Name < datasetDescriptions[j].Name
@@ -58,15 +153,22 @@ func NewOracleDataLayer(conf *common.Config, logger common.Logger, metrics commo
 	if err != nil {
 		return nil, err
 	}
+	connections, err := newConnections(conf, logger, metrics)
 	l := &OracleDatalayer{
-		datasets: map[string]*Dataset{},
-		logger:   logger,
-		metrics:  metrics,
-		config:   conf,
-		db:       oracledb,
+		datasets:    map[string]*Dataset{},
+		logger:      logger,
+		metrics:     metrics,
+		config:      conf,
+		db:          oracledb,
+		connections: connections,
+	}
+	if err != nil {
+		l.Stop(context.Background())
+		return nil, err
 	}
 	err = l.UpdateConfiguration(conf)
 	if err != nil {
+		l.Stop(context.Background())
 		return nil, err
 	}
 	return l, nil
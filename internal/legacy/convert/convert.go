@@ -0,0 +1,177 @@
+// Package convert translates configurations of the legacy oracle layer to the common-datalayer format
+package convert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	common "github.com/mimiro-io/common-datalayer"
+	layer "github.com/mimiro-io/oracle-datalayer/internal"
+	"github.com/mimiro-io/oracle-datalayer/internal/legacy/conf"
+)
+
+// Convert creates a common-datalayer configuration equivalent to a legacy configuration.
+// Table mappings become readable datasets, post mappings become writable datasets.
+// Everything that can not be translated is reported in the returned notes.
+func Convert(legacy *conf.Datalayer) (*common.Config, []string) {
+	var notes []string
+	note := func(format string, args ...any) {
+		notes = append(notes, fmt.Sprintf(format, args...))
+	}
+
+	config := &common.Config{
+		LayerServiceConfig: &common.LayerServiceConfig{ServiceName: legacy.Id},
+		NativeSystemConfig: common.NativeSystemConfig{
+			layer.OracleHostname: legacy.DatabaseServer,
+			layer.OraclePort:     strconv.Itoa(legacy.Port),
+			layer.OracleDB:       legacy.Service,
+			layer.OracleUser:     legacy.User,
+			layer.OraclePassword: legacy.Password,
+		},
+	}
+	note("layer_config: port is not part of the legacy config and must be set")
+	if legacy.User == "" || legacy.Password == "" {
+		note("system_config: user and password are not set, provide them with ORACLE_USER and ORACLE_PASSWORD")
+	}
+
+	for _, tm := range legacy.TableMappings {
+		config.DatasetDefinitions = append(config.DatasetDefinitions, convertTableMapping(legacy, tm, note))
+	}
+	for _, pm := range legacy.PostMappings {
+		name := pm.DatasetName
+		if name == "" {
+			name = pm.TableName
+		}
+		// a post mapping for the table of a table mapping adds writes to the same dataset
+		def := config.GetDatasetDefinition(name)
+		if def == nil {
+			def = &common.DatasetDefinition{
+				DatasetName:  name,
+				SourceConfig: map[string]any{layer.TableName: pm.TableName},
+				OutgoingMappingConfig: &common.OutgoingMappingConfig{
+					BaseURI: legacy.BaseNameSpace + pm.TableName + "/",
+					MapAll:  true,
+				},
+			}
+			config.DatasetDefinitions = append(config.DatasetDefinitions, def)
+		} else if !strings.EqualFold(def.SourceConfig[layer.TableName].(string), pm.TableName) {
+			note("dataset %s: post mapping writes to table %s, but the dataset reads from %s", name, pm.TableName, def.SourceConfig[layer.TableName])
+		}
+		def.IncomingMappingConfig = convertPostMapping(legacy, name, pm, note)
+		if pm.Config != nil {
+			note("dataset %s: connection overrides in config are not translated, all datasets use system_config", name)
+		}
+	}
+	return config, notes
+}
+
+func convertTableMapping(legacy *conf.Datalayer, tm *conf.TableMapping, note func(string, ...any)) *common.DatasetDefinition {
+	table := tm.TableName
+	if legacy.Schema != "" {
+		table = legacy.Schema + "." + tm.TableName
+	}
+	namespace := tm.TableName
+	if tm.NameSpace != "" {
+		namespace = tm.NameSpace
+	}
+	def := &common.DatasetDefinition{
+		// legacy datasets are named by table
+		DatasetName:  tm.TableName,
+		SourceConfig: map[string]any{layer.TableName: table},
+		OutgoingMappingConfig: &common.OutgoingMappingConfig{
+			BaseURI: legacy.BaseNameSpace + namespace + "/",
+			MapAll:  true,
+		},
+	}
+	if tm.SinceColumn != "" {
+		def.SourceConfig[layer.SinceColumn] = tm.SinceColumn
+	}
+	if tm.CustomQuery != "" {
+		note("dataset %s: custom query is not translated, legacy queries use a different placeholder format. see the query option", tm.TableName)
+	}
+	if tm.Config != nil {
+		note("dataset %s: connection overrides in config are not translated, all datasets use system_config", tm.TableName)
+	}
+	switch {
+	case len(tm.Types) == 1:
+		def.OutgoingMappingConfig.DefaultType = tm.Types[0]
+	case len(tm.Types) > 1:
+		// the legacy layer ignores multiple types as well
+		note("dataset %s: multiple types are not translated", tm.TableName)
+	}
+
+	idConstructor := tm.EntityIdConstructor
+	if idConstructor == "" {
+		idConstructor = "%s"
+	}
+	var ignored []string
+	omc := def.OutgoingMappingConfig
+	for _, cm := range tm.ColumnMappings {
+		entityProperty := strings.TrimPrefix(cm.PropertyName, "ns0:")
+		switch {
+		case cm.IgnoreColumn:
+			ignored = append(ignored, cm.FieldName)
+			continue
+		case cm.IsEntity || cm.IdTemplate != "" || len(cm.ColumnMappings) > 0:
+			note("dataset %s: nested entity mapping of column %s is not translated", tm.TableName, cm.FieldName)
+		}
+		if cm.IsIdColumn {
+			omc.PropertyMappings = append(omc.PropertyMappings, &common.ItemToEntityPropertyMapping{
+				Property:        cm.FieldName,
+				IsIdentity:      true,
+				URIValuePattern: legacy.BaseUri + strings.Replace(idConstructor, "%s", "{value}", 1),
+			})
+		}
+		if cm.IsReference {
+			if entityProperty == "" {
+				entityProperty = cm.FieldName
+			}
+			omc.PropertyMappings = append(omc.PropertyMappings, &common.ItemToEntityPropertyMapping{
+				EntityProperty:  entityProperty,
+				Property:        cm.FieldName,
+				IsReference:     true,
+				URIValuePattern: strings.Replace(cm.ReferenceTemplate, "%s", "{value}", 1),
+			})
+		} else if entityProperty != "" {
+			omc.PropertyMappings = append(omc.PropertyMappings, &common.ItemToEntityPropertyMapping{
+				EntityProperty: entityProperty,
+				Property:       cm.FieldName,
+			})
+		}
+	}
+	if len(ignored) > 0 {
+		note("dataset %s: ignored columns %s can not be left out with map_all. replace map_all with property mappings "+
+			"for the other columns (see the generate command)", tm.TableName, strings.Join(ignored, ", "))
+	}
+	return def
+}
+
+func convertPostMapping(legacy *conf.Datalayer, name string, pm *conf.PostMapping, note func(string, ...any)) *common.IncomingMappingConfig {
+	imc := &common.IncomingMappingConfig{BaseURI: legacy.BaseNameSpace}
+	for _, fm := range pm.FieldMappings {
+		if strings.EqualFold(fm.FieldName, pm.IdColumn) {
+			imc.PropertyMappings = append(imc.PropertyMappings, &common.EntityToItemPropertyMapping{
+				Property:             fm.FieldName,
+				IsIdentity:           true,
+				StripReferencePrefix: true,
+			})
+			continue
+		}
+		imc.PropertyMappings = append(imc.PropertyMappings, &common.EntityToItemPropertyMapping{
+			EntityProperty: fm.FieldName,
+			Property:       fm.FieldName,
+		})
+	}
+	if pm.IdColumn == "" {
+		note("dataset %s: post mapping has no idColumn, an identity mapping must be added", name)
+	} else {
+		note("dataset %s: the legacy layer read %s from an entity property, the converted mapping uses the entity id", name, pm.IdColumn)
+	}
+	note("dataset %s: legacy posts match entity properties by local name in any namespace, "+
+		"the converted mapping expects them in %s", name, imc.BaseURI)
+	if pm.Query != "" {
+		note("dataset %s: custom insert statement is not translated, entities are upserted by key", name)
+	}
+	return imc
+}
@@ -0,0 +1,59 @@
+package convert
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	layer "github.com/mimiro-io/oracle-datalayer/internal"
+	"github.com/mimiro-io/oracle-datalayer/internal/legacy/conf"
+)
+
+func TestConvert(t *testing.T) {
+	b, err := os.ReadFile("../resources/test/test-config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := &conf.Datalayer{}
+	if err = json.Unmarshal(b, legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	config, notes := Convert(legacy)
+	if config.LayerServiceConfig.ServiceName != "test-import" || config.NativeSystemConfig[layer.OraclePort] != "1521" ||
+		config.NativeSystemConfig[layer.OracleDB] != "ORCL" {
+		t.Fatalf("unexpected config: %+v, %v", config.LayerServiceConfig, config.NativeSystemConfig)
+	}
+
+	animals := config.GetDatasetDefinition("ANIMAL_TABLE")
+	if animals == nil || animals.SourceConfig[layer.TableName] != "ANIMAL.ANIMAL_TABLE" {
+		t.Fatalf("unexpected dataset: %+v", animals)
+	}
+	omc := animals.OutgoingMappingConfig
+	if !omc.MapAll || omc.BaseURI != "http://data.test.io/newtestnamespace/animal/" ||
+		omc.DefaultType != "http://data.test.io/newtestnamespace/Animal" {
+		t.Fatalf("unexpected outgoing mapping: %+v", omc)
+	}
+	if len(omc.PropertyMappings) != 1 || !omc.PropertyMappings[0].IsIdentity ||
+		omc.PropertyMappings[0].URIValuePattern != "http://data.test.io/testnamespace/animal/{value}" {
+		t.Fatalf("unexpected identity mapping: %+v", omc.PropertyMappings)
+	}
+
+	testdata := config.GetDatasetDefinition("datahub.Testdata")
+	if testdata == nil || testdata.IncomingMappingConfig == nil {
+		t.Fatalf("expected writable dataset for post mapping, got %+v", testdata)
+	}
+	in := testdata.IncomingMappingConfig.PropertyMappings
+	if len(in) != 3 || !in[0].IsIdentity || in[1].EntityProperty != "foo" || in[1].Property != "foo" {
+		t.Fatalf("unexpected incoming mappings: %+v", in)
+	}
+
+	var found bool
+	for _, n := range notes {
+		found = found || strings.Contains(n, "dataset datahub.Testdata: custom insert statement is not translated")
+	}
+	if !found {
+		t.Fatalf("expected note about insert query, got %v", notes)
+	}
+}
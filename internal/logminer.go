@@ -0,0 +1,319 @@
+package layer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	common "github.com/mimiro-io/common-datalayer"
+	egdm "github.com/mimiro-io/entity-graph-data-model"
+)
+
+// newLogMinerIterator reads changes of the dataset table from the redo logs.
+//
+// Without a since token, the current content of the table is emitted as of the current scn.
+// Otherwise, all operations committed after the scn in the token are mined with DBMS_LOGMNR.
+// The token carries two scns: the scn to start mining from, which is before the start of all
+// transactions that were open when the token was created, and the commit scn of the last
+// emitted change.
+func (d *Dataset) newLogMinerIterator(mapper *common.Mapper, since string, limit int) (common.EntityIterator, common.LayerError) {
+	ctx := context.Background()
+	// log miner sessions are bound to a database session, so all statements run on one connection
+	conn, err := d.db.pool.Conn(ctx)
+	if err != nil {
+		return nil, ErrConnection(err)
+	}
+	it := &logMinerIterator{logger: d.logger, mapper: mapper, conn: conn, limit: limit}
+
+	owner, table := splitTableName(d.datasetDefinition.SourceConfig[TableName].(string))
+	if owner == "" {
+		err = conn.QueryRowContext(ctx, "SELECT SYS_CONTEXT('USERENV', 'CURRENT_SCHEMA') FROM dual").Scan(&owner)
+		if err != nil {
+			return it.fail("failed to get current schema", err)
+		}
+	}
+	it.columns, err = d.outgoingColumns(ctx, conn, owner, table)
+	if err != nil {
+		return it.fail("failed to get table columns", err)
+	}
+
+	err = conn.QueryRowContext(ctx, "SELECT CURRENT_SCN FROM V$DATABASE").Scan(&it.endSCN)
+	if err != nil {
+		return it.fail("failed to get current scn", err)
+	}
+	// changes of transactions that are still open will be committed after endSCN,
+	// the next page must start mining before the oldest of them started
+	var oldest sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT MIN(START_SCN) FROM V$TRANSACTION").Scan(&oldest)
+	if err != nil {
+		return it.fail("failed to get open transactions", err)
+	}
+	it.restartSCN = it.endSCN
+	if oldest.Valid && oldest.Int64 < it.endSCN {
+		it.restartSCN = oldest.Int64
+	}
+
+	if since == "" {
+		query := "SELECT " + strings.Join(it.columns, ", ") + " FROM " + owner + "." + table +
+			" AS OF SCN " + strconv.FormatInt(it.endSCN, 10)
+		d.logger.Debug(fmt.Sprintf("log miner snapshot query for dataset %s: %s", d.Name(), query), "dataset", d.Name())
+		it.rows, err = conn.QueryContext(ctx, query)
+		if err != nil {
+			return it.fail("failed to execute snapshot query", err)
+		}
+		it.snapshot = true
+		it.rowBuf = make([]any, len(it.columns))
+		for i := range it.rowBuf {
+			it.rowBuf[i] = &sql.NullString{}
+		}
+		return it, nil
+	}
+
+	start, last, err := decodeToken(since)
+	if err != nil {
+		return it.fail("failed to decode since token", err)
+	}
+	it.startSCN, err = strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return it.fail("failed to decode since token", fmt.Errorf("invalid start scn in token %s", since))
+	}
+	it.lastCommit = last
+
+	err = addLogFiles(ctx, conn, it.startSCN)
+	if err != nil {
+		return it.fail("failed to add log files", err)
+	}
+	_, err = conn.ExecContext(ctx, "BEGIN DBMS_LOGMNR.START_LOGMNR(STARTSCN => :1, ENDSCN => :2, "+
+		"OPTIONS => DBMS_LOGMNR.DICT_FROM_ONLINE_CATALOG + DBMS_LOGMNR.COMMITTED_DATA_ONLY); END;",
+		it.startSCN, it.endSCN)
+	if err != nil {
+		return it.fail("failed to start log miner", err)
+	}
+	it.mining = true
+
+	query := logMinerQuery(owner, table, it.columns)
+	d.logger.Debug(fmt.Sprintf("log miner query for dataset %s: %s", d.Name(), query), "dataset", d.Name())
+	it.rows, err = conn.QueryContext(ctx, query, owner, table, it.lastCommit)
+	if err != nil {
+		return it.fail("failed to query log miner contents", err)
+	}
+	it.rowBuf = make([]any, len(it.columns)+2)
+	it.rowBuf[0] = &it.operation
+	it.rowBuf[1] = &it.commitSCN
+	for i := range it.columns {
+		it.rowBuf[i+2] = &sql.NullString{}
+	}
+	return it, nil
+}
+
+// logMinerQuery selects the mined column values of all committed row operations on the given table.
+// for deletes, the values are taken from the undo part of the redo record, which contains the deleted row.
+// with COMMITTED_DATA_ONLY, log miner returns the operations grouped by transaction in commit order
+func logMinerQuery(owner, table string, columns []string) string {
+	var sb strings.Builder
+	sb.WriteString("SELECT OPERATION, COMMIT_SCN")
+	for _, col := range columns {
+		spec := strings.ReplaceAll(owner+"."+table+"."+col, "'", "''")
+		sb.WriteString(", DBMS_LOGMNR.MINE_VALUE(CASE WHEN OPERATION = 'DELETE' THEN UNDO_VALUE ELSE REDO_VALUE END, '")
+		sb.WriteString(spec)
+		sb.WriteString("') AS ")
+		sb.WriteString(col)
+	}
+	sb.WriteString(" FROM V$LOGMNR_CONTENTS WHERE SEG_OWNER = :1 AND TABLE_NAME = :2" +
+		" AND OPERATION IN ('INSERT', 'UPDATE', 'DELETE') AND COMMIT_SCN > :3")
+	return sb.String()
+}
+
+// addLogFiles registers all online and archived redo logs that may contain changes after scn
+// with the log miner session.
+func addLogFiles(ctx context.Context, conn *sql.Conn, scn int64) error {
+	rows, err := conn.QueryContext(ctx, "SELECT MEMBER FROM V$LOGFILE UNION "+
+		"SELECT NAME FROM V$ARCHIVED_LOG WHERE NEXT_CHANGE# > :1 AND NAME IS NOT NULL AND STANDBY_DEST = 'NO'", scn)
+	if err != nil {
+		return err
+	}
+	var files []string
+	for rows.Next() {
+		var f string
+		if err = rows.Scan(&f); err != nil {
+			rows.Close()
+			return err
+		}
+		files = append(files, f)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	option := "DBMS_LOGMNR.NEW"
+	for _, f := range files {
+		_, err = conn.ExecContext(ctx, "BEGIN DBMS_LOGMNR.ADD_LOGFILE(LOGFILENAME => :1, OPTIONS => "+option+"); END;", f)
+		if err != nil {
+			if strings.Contains(err.Error(), "ORA-65040") {
+				// operation not allowed from within a pluggable database.
+				// in a pdb, log miner selects the log files for the given start scn itself
+				return nil
+			}
+			if strings.Contains(err.Error(), "ORA-01289") { // duplicate log file, already added
+				continue
+			}
+			return err
+		}
+		option = "DBMS_LOGMNR.ADDFILE"
+	}
+	return nil
+}
+
+// outgoingColumns lists the upper case table columns read by the outgoing mapping.
+// with map_all, all columns of the table are read
+func (d *Dataset) outgoingColumns(ctx context.Context, conn *sql.Conn, owner, table string) ([]string, error) {
+	omc := d.datasetDefinition.OutgoingMappingConfig
+	if omc == nil {
+		return nil, fmt.Errorf("no outgoing mapping defined for dataset %s", d.Name())
+	}
+	if !omc.MapAll {
+		var cols []string
+		for _, pm := range omc.PropertyMappings {
+			cols = append(cols, strings.ToUpper(pm.Property))
+		}
+		return cols, nil
+	}
+	rows, err := conn.QueryContext(ctx,
+		"SELECT COLUMN_NAME FROM ALL_TAB_COLUMNS WHERE OWNER = :1 AND TABLE_NAME = :2 ORDER BY COLUMN_ID", owner, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cols []string
+	for rows.Next() {
+		var col string
+		if err = rows.Scan(&col); err != nil {
+			return nil, err
+		}
+		cols = append(cols, col)
+	}
+	if len(cols) == 0 && rows.Err() == nil {
+		return nil, fmt.Errorf("table %s.%s not found", owner, table)
+	}
+	return cols, rows.Err()
+}
+
+// splitTableName splits an optionally schema qualified table name into upper case owner and table.
+// owner is empty if the name is not qualified
+func splitTableName(name string) (string, string) {
+	owner, table, found := strings.Cut(strings.ToUpper(name), ".")
+	if !found {
+		return "", owner
+	}
+	return owner, table
+}
+
+type logMinerIterator struct {
+	logger     common.Logger
+	mapper     *common.Mapper
+	conn       *sql.Conn
+	rows       *sql.Rows
+	columns    []string
+	rowBuf     []any
+	operation  string
+	commitSCN  int64
+	limit      int
+	count      int
+	snapshot   bool
+	mining     bool
+	cut        bool
+	startSCN   int64
+	restartSCN int64
+	endSCN     int64
+	lastCommit int64
+}
+
+// fail releases the resources of a partially initialized iterator
+func (it *logMinerIterator) fail(msg string, err error) (common.EntityIterator, common.LayerError) {
+	it.logger.Error(msg, "error", err)
+	it.Close()
+	return nil, ErrQuery(err)
+}
+
+func (it *logMinerIterator) Context() *egdm.Context {
+	ctx := egdm.NewNamespaceContext()
+	return ctx.AsContext()
+}
+
+func (it *logMinerIterator) Next() (*egdm.Entity, common.LayerError) {
+	if it.cut || !it.rows.Next() {
+		if it.rows.Err() != nil {
+			it.logger.Error("failed to read rows", "error", it.rows.Err())
+			return nil, common.Err(it.rows.Err(), common.LayerErrorInternal)
+		}
+		return nil, nil
+	}
+	err := it.rows.Scan(it.rowBuf...)
+	if err != nil {
+		it.logger.Error("failed to scan row", "error", err)
+		return nil, common.Err(err, common.LayerErrorInternal)
+	}
+
+	if !it.snapshot {
+		// pages are only cut between transactions, so that the commit scn is a valid token
+		if it.limit > 0 && it.count >= it.limit && it.commitSCN != it.lastCommit {
+			it.cut = true
+			return nil, nil
+		}
+		it.lastCommit = it.commitSCN
+		it.count++
+	}
+
+	values := it.rowBuf
+	if !it.snapshot {
+		values = it.rowBuf[2:]
+	}
+	ri := &RowItem{Columns: it.columns, Map: make(map[string]any)}
+	for i, col := range it.columns {
+		ri.Map[col] = values[i]
+	}
+	entity := egdm.NewEntity()
+	err = it.mapper.MapItemToEntity(ri, entity)
+	if err != nil {
+		it.logger.Error("failed to map row", "error", err, "row", fmt.Sprintf("%+v", ri))
+		return nil, common.Err(err, common.LayerErrorInternal)
+	}
+	if it.operation == "DELETE" {
+		entity.IsDeleted = true
+	}
+	return entity, nil
+}
+
+func (it *logMinerIterator) Token() (*egdm.Continuation, common.LayerError) {
+	cont := egdm.NewContinuation()
+	if it.cut {
+		// continue with the next transaction of the same log miner range
+		cont.Token = encodeToken(strconv.FormatInt(it.startSCN, 10), it.lastCommit)
+	} else {
+		cont.Token = encodeToken(strconv.FormatInt(it.restartSCN, 10), it.endSCN)
+	}
+	return cont, nil
+}
+
+func (it *logMinerIterator) Close() common.LayerError {
+	var err error
+	if it.rows != nil {
+		err = it.rows.Close()
+	}
+	if it.mining {
+		if _, err2 := it.conn.ExecContext(context.Background(), "BEGIN DBMS_LOGMNR.END_LOGMNR; END;"); err2 != nil {
+			it.logger.Warn("failed to end log miner session", "error", err2)
+		}
+		it.mining = false
+	}
+	if err2 := it.conn.Close(); err2 != nil && err == nil {
+		err = err2
+	}
+	if err != nil {
+		return common.Err(err, common.LayerErrorInternal)
+	}
+	return nil
+}
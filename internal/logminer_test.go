@@ -0,0 +1,37 @@
+package layer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLogMinerQuery(t *testing.T) {
+	t.Run("should mine deleted values from undo", func(t *testing.T) {
+		q := logMinerQuery("TESTUSER", "SAMPLE", []string{"ID", "NAME"})
+		expected := "SELECT OPERATION, COMMIT_SCN, " +
+			"DBMS_LOGMNR.MINE_VALUE(CASE WHEN OPERATION = 'DELETE' THEN UNDO_VALUE ELSE REDO_VALUE END, 'TESTUSER.SAMPLE.ID') AS ID, " +
+			"DBMS_LOGMNR.MINE_VALUE(CASE WHEN OPERATION = 'DELETE' THEN UNDO_VALUE ELSE REDO_VALUE END, 'TESTUSER.SAMPLE.NAME') AS NAME " +
+			"FROM V$LOGMNR_CONTENTS WHERE SEG_OWNER = :1 AND TABLE_NAME = :2 " +
+			"AND OPERATION IN ('INSERT', 'UPDATE', 'DELETE') AND COMMIT_SCN > :3"
+		if q != expected {
+			t.Fatalf("unexpected query: %s", q)
+		}
+	})
+	t.Run("should escape quotes in column spec", func(t *testing.T) {
+		q := logMinerQuery("TESTUSER", "SAMPLE", []string{"IT'S"})
+		if !strings.Contains(q, "'TESTUSER.SAMPLE.IT''S'") {
+			t.Fatalf("unexpected query: %s", q)
+		}
+	})
+}
+
+func TestSplitTableName(t *testing.T) {
+	owner, table := splitTableName("sample")
+	if owner != "" || table != "SAMPLE" {
+		t.Fatalf("unexpected split: %s, %s", owner, table)
+	}
+	owner, table = splitTableName("testuser.sample")
+	if owner != "TESTUSER" || table != "SAMPLE" {
+		t.Fatalf("unexpected split: %s, %s", owner, table)
+	}
+}
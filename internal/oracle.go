@@ -1,45 +1,250 @@
 package layer
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	egdm "github.com/mimiro-io/entity-graph-data-model"
 
 	common "github.com/mimiro-io/common-datalayer"
 	go_ora "github.com/sijms/go-ora/v2"
 )
 
+// oracleDB owns a connection pool that is shared by all datasets of the layer using the same connection
 type oracleDB struct {
-	connector driver.Connector
+	name    string
+	pool    *sql.DB
+	logger  common.Logger
+	metrics common.Metrics
+	done    chan struct{}
+
+	mu  sync.RWMutex
+	err error // result of the last ping, nil while connected
 }
 
+// newOracleDB opens the default connection of the system config
 func newOracleDB(conf *common.Config, logger common.Logger, metrics common.Metrics) (*oracleDB, error) {
 	c := oracleConf(conf)
+	if err := validateSystemConfig(c); err != nil {
+		return nil, ErrInvalidConfig(err)
+	}
+	return openOracleDB("", c, logger, metrics)
+}
+
+// newConnections opens the named connections of the system config
+func newConnections(conf *common.Config, logger common.Logger, metrics common.Metrics) (map[string]*oracleDB, error) {
+	c := oracleConf(conf)
+	connections := map[string]*oracleDB{}
+	for _, name := range c.connectionNames() {
+		cc, err := c.connection(name)
+		if err != nil {
+			return connections, ErrInvalidConfig(err)
+		}
+		db, err := openOracleDB(name, cc, logger, metrics)
+		if err != nil {
+			return connections, err
+		}
+		connections[name] = db
+	}
+	return connections, nil
+}
+
+// openOracleDB opens and pings a connection pool. name is empty for the default connection.
+// if the database is not reachable, the pool is returned in a disconnected state, see watch
+func openOracleDB(name string, c oraConf, logger common.Logger, metrics common.Metrics) (*oracleDB, error) {
+	port, _ := c.int(OraclePort)
 	connStr := go_ora.BuildUrl(c.str(OracleHostname),
-		c.int(OraclePort),
+		port,
 		c.str(OracleDB),
 		c.str(OracleUser),
 		c.str(OraclePassword),
 		nil)
-	connector := go_ora.NewConnector(connStr)
-	connPool := sql.OpenDB(connector)
-	defer connPool.Close()
-	perr := connPool.Ping()
-	if perr != nil {
-		return nil, ErrConnection(perr)
+	pool := sql.OpenDB(go_ora.NewConnector(connStr))
+	err := configurePool(pool, c)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+	db := &oracleDB{name: name, pool: pool, logger: logger, metrics: metrics, done: make(chan struct{})}
+	db.ping()
+	if err = db.healthy(); err != nil && logger != nil {
+		logger.Error("database not reachable, retrying in background", "connection", name, "error", err)
+	}
+	go db.watch(healthCheckInterval, maxReconnectDelay)
+	go db.reportStats(poolStatsInterval)
+	return db, nil
+}
+
+const (
+	healthCheckInterval = 30 * time.Second
+	minReconnectDelay   = time.Second
+	maxReconnectDelay   = time.Minute
+)
+
+// ping checks the connection and records the result
+func (db *oracleDB) ping() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := db.pool.PingContext(ctx)
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.logger != nil {
+		if err == nil && db.err != nil {
+			db.logger.Info("database connection restored", "connection", db.name)
+		} else if err != nil && db.err == nil {
+			db.logger.Error("database connection lost", "connection", db.name, "error", err)
+		}
+	}
+	db.err = err
+}
+
+// healthy returns the error of the last ping, or nil if the database was reachable
+func (db *oracleDB) healthy() error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.err != nil {
+		return ErrConnection(db.err)
+	}
+	return nil
+}
+
+// watch pings the database every interval. while the database is not reachable, it retries
+// with exponential backoff up to maxDelay. database/sql replaces broken connections by itself,
+// so the pool recovers as soon as the database is reachable again.
+func (db *oracleDB) watch(interval, maxDelay time.Duration) {
+	delay := minReconnectDelay
+	for {
+		wait := interval
+		if db.healthy() != nil {
+			wait = delay
+			delay = min(delay*2, maxDelay)
+		} else {
+			delay = minReconnectDelay
+		}
+		select {
+		case <-db.done:
+			return
+		case <-time.After(wait):
+			db.ping()
+		}
+	}
+}
+
+// isConnectionLost tells if an error is caused by a broken connection, so that the operation can be retried
+func isConnectionLost(err error) bool {
+	var lerr common.LayerError
+	if errors.As(err, &lerr) && lerr.Underlying() != nil {
+		err = lerr.Underlying()
+	}
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	// ORA-03113: end-of-file on communication channel, ORA-03114: not connected to ORACLE
+	msg := err.Error()
+	for _, s := range []string{"ORA-03113", "ORA-03114", "broken pipe", "connection reset"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// configurePool applies the optional pool settings in system_config to the pool
+func configurePool(pool *sql.DB, c oraConf) error {
+	maxOpen, err := c.optInt(MaxOpenConns, 0)
+	if err != nil {
+		return err
+	}
+	pool.SetMaxOpenConns(maxOpen)
+	maxIdle, err := c.optInt(MaxIdleConns, 2) // 2 is the database/sql default
+	if err != nil {
+		return err
+	}
+	pool.SetMaxIdleConns(maxIdle)
+	lifetime, err := c.optDuration(ConnMaxLifetime, 0)
+	if err != nil {
+		return err
 	}
-	return &oracleDB{connector}, nil
+	pool.SetConnMaxLifetime(lifetime)
+	idleTime, err := c.optDuration(ConnMaxIdleTime, 0)
+	if err != nil {
+		return err
+	}
+	pool.SetConnMaxIdleTime(idleTime)
+	return nil
+}
+
+const poolStatsInterval = 15 * time.Second
+
+// reportStats periodically emits the pool statistics as gauges until the pool is closed
+func (db *oracleDB) reportStats(interval time.Duration) {
+	if db.metrics == nil {
+		return
+	}
+	var tags []string
+	if db.name != "" {
+		tags = []string{"connection:" + db.name}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-db.done:
+			return
+		case <-ticker.C:
+			stats := db.pool.Stats()
+			connected := 1.0
+			if db.healthy() != nil {
+				connected = 0
+			}
+			gauges := map[string]float64{
+				"oracle.connected":          connected,
+				"oracle.pool.open":          float64(stats.OpenConnections),
+				"oracle.pool.in_use":        float64(stats.InUse),
+				"oracle.pool.idle":          float64(stats.Idle),
+				"oracle.pool.wait_count":    float64(stats.WaitCount),
+				"oracle.pool.wait_ms":       float64(stats.WaitDuration.Milliseconds()),
+				"oracle.pool.max_open":      float64(stats.MaxOpenConnections),
+				"oracle.pool.closed_idle":   float64(stats.MaxIdleClosed + stats.MaxIdleTimeClosed),
+				"oracle.pool.closed_expire": float64(stats.MaxLifetimeClosed),
+			}
+			for name, value := range gauges {
+				if err := db.metrics.Gauge(name, value, tags, 1); err != nil {
+					db.logger.Warn("failed to report pool metric", "metric", name, "error", err)
+				}
+			}
+		}
+	}
+}
+
+func (db *oracleDB) close() error {
+	close(db.done)
+	return db.pool.Close()
 }
 
 type RowItem struct {
-	Map     map[string]any
-	Columns []string
-	Values  []any
-	deleted bool
+	Map      map[string]any
+	Columns  []string
+	Values   []any
+	deleted  bool
+	recorded uint64
+	entity   *egdm.Entity // source of the row, kept for rejects
 }
 
 func (r *RowItem) GetValue(name string) any {
 	val := r.Map[name]
 	switch v := val.(type) {
+	case columnValue:
+		return v.value()
 	case *sql.NullBool:
 		return v.Valid && v.Bool
 	case *sql.NullString:
@@ -0,0 +1,65 @@
+package layer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	common "github.com/mimiro-io/common-datalayer"
+)
+
+func TestDegradedStart(t *testing.T) {
+	conf, metrics, _ := testDeps()
+	// nothing listens on port 1, so the connection is refused
+	conf.NativeSystemConfig[OracleHostname] = "localhost"
+	conf.NativeSystemConfig[OraclePort] = "1"
+	logger := common.NewLogger("test", "text", "error")
+
+	service, err := NewOracleDataLayer(conf, logger, metrics)
+	if err != nil {
+		t.Fatalf("expected layer to start without database, got %v", err)
+	}
+	defer service.Stop(context.Background())
+	dl := service.(*OracleDatalayer)
+	if err := dl.Health(); err == nil || !strings.Contains(err.Error(), "database connection error") {
+		t.Fatalf("expected connection error, got %v", err)
+	}
+	descriptions := dl.DatasetDescriptions()
+	if len(descriptions) != 1 || descriptions[0].Metadata["connection_error"] == nil {
+		t.Fatalf("expected connection error in dataset metadata, got %+v", descriptions)
+	}
+}
+
+func TestRetryRead(t *testing.T) {
+	ds := &Dataset{logger: common.NewLogger("test", "text", "error"), datasetDefinition: testDefinition(nil)}
+	lost := ErrQuery(fmt.Errorf("ORA-03113: end-of-file on communication channel"))
+	defer func(d time.Duration) { readRetryDelay = d }(readRetryDelay)
+	readRetryDelay = 0
+
+	t.Run("should retry reads on lost connections", func(t *testing.T) {
+		attempts := 0
+		it, err := ds.retryRead(func() (common.EntityIterator, common.LayerError) {
+			attempts++
+			if attempts < 3 {
+				return nil, lost
+			}
+			return &dbIterator{}, nil
+		})
+		if err != nil || it == nil || attempts != 3 {
+			t.Fatalf("expected success after 3 attempts, got %v after %d", err, attempts)
+		}
+	})
+	t.Run("should not retry other errors", func(t *testing.T) {
+		attempts := 0
+		_, err := ds.retryRead(func() (common.EntityIterator, common.LayerError) {
+			attempts++
+			return nil, ErrQuery(errors.New("ORA-00942: table or view does not exist"))
+		})
+		if err == nil || attempts != 1 {
+			t.Fatalf("expected one failed attempt, got %v after %d", err, attempts)
+		}
+	})
+}
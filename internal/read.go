@@ -5,85 +5,188 @@ import (
 	"database/sql"
 	"encoding/base64"
 	"fmt"
-	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	common "github.com/mimiro-io/common-datalayer"
 	egdm "github.com/mimiro-io/entity-graph-data-model"
 )
 
 func (d *Dataset) Changes(since string, limit int, latestOnly bool) (common.EntityIterator, common.LayerError) {
-	if latestOnly {
-		// the layer does not know if the given table is a "change" table or not, so we cannot support this mode with confidence
+	if latestOnly && !isChangeLog(d.datasetDefinition) {
+		// the layer can only reduce a table to the latest versions if it is declared as change log
 		return nil, common.Err(fmt.Errorf("latest only operation not supported"), common.LayerNotSupported)
 	}
 
 	mapper := common.NewMapper(d.logger, d.datasetDefinition.IncomingMappingConfig, d.datasetDefinition.OutgoingMappingConfig)
-	return d.newIterator(mapper, since, limit)
+	if d.datasetDefinition.SourceConfig[ChangeTracking] == ChangeTrackingLogMiner {
+		if latestOnly {
+			return nil, common.Err(fmt.Errorf("latest only operation not supported with log miner change tracking"), common.LayerNotSupported)
+		}
+		return d.retryRead(func() (common.EntityIterator, common.LayerError) {
+			return d.newLogMinerIterator(mapper, since, limit)
+		})
+	}
+	return d.retryRead(func() (common.EntityIterator, common.LayerError) {
+		return d.newIterator(mapper, since, limit, latestOnly)
+	})
 }
 
 func (d *Dataset) Entities(from string, limit int) (common.EntityIterator, common.LayerError) {
-	// the layer does not know if the given table is a "change" table or not, so implement /entities as /changes
-	// TODO: consider adding source config options to allow for different behavior
-	return d.Changes(from, limit, false)
+	mapper := common.NewMapper(d.logger, d.datasetDefinition.IncomingMappingConfig, d.datasetDefinition.OutgoingMappingConfig)
+	return d.retryRead(func() (common.EntityIterator, common.LayerError) {
+		return d.newEntitiesIterator(mapper, from, limit)
+	})
+}
+
+const readRetries = 3
+
+// readRetryDelay is the delay before the first retry of a read, it doubles with every attempt
+var readRetryDelay = 500 * time.Millisecond
+
+// retryRead starts a read, and starts it again if the connection broke. reads have no side
+// effects, and the broken connection is replaced by the pool. once an iterator is returned,
+// entities may have been sent, so failures while iterating are not retried
+func (d *Dataset) retryRead(read func() (common.EntityIterator, common.LayerError)) (common.EntityIterator, common.LayerError) {
+	delay := readRetryDelay
+	for attempt := 1; ; attempt++ {
+		it, err := read()
+		if err == nil || attempt > readRetries || !isConnectionLost(err) {
+			if err != nil {
+				return nil, err
+			}
+			return it, nil
+		}
+		d.logger.Warn("connection lost, retrying read", "dataset", d.Name(), "attempt", attempt, "error", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
 }
 
-func (d *Dataset) newIterator(mapper *common.Mapper, since string, limit int) (*dbIterator, common.LayerError) {
-	sinceCol, _ := d.datasetDefinition.SourceConfig[SinceColumn].(string)
-	db := sql.OpenDB(d.db.connector)
+func (d *Dataset) newIterator(mapper *common.Mapper, since string, limit int, latestOnly bool) (*dbIterator, common.LayerError) {
+	sinceCol := sinceColumn(d.datasetDefinition)
+	// with ora_rowscn change tracking, the watermark already is an scn
+	withSCN := d.datasetDefinition.SourceConfig[SnapshotSCN] == true && sinceCol != "ORA_ROWSCN"
+	db := d.db.pool
 	ctx := context.Background() // no timeout because we want to support long running stream operations
 
+	if sinceCol == "ORA_ROWSCN" && d.datasetDefinition.SourceConfig[RequireRowDeps] == true {
+		err := checkRowDependencies(ctx, db, d.datasetDefinition.SourceConfig[TableName].(string))
+		if err != nil {
+			d.logger.Error("row dependencies check failed", "error", err)
+			return nil, ErrQuery(err)
+		}
+	}
+
+	var scn int64
+	if sinceCol != "" && withSCN {
+		// the scn is read before the snapshot is taken, so that it is a safe lower bound for the next page
+		err := db.QueryRowContext(ctx, "SELECT DBMS_FLASHBACK.GET_SYSTEM_CHANGE_NUMBER FROM DUAL").Scan(&scn)
+		if err != nil {
+			d.logger.Error("failed to get current scn", "error", err)
+			return nil, ErrQuery(err)
+		}
+	}
+
+	// the max since query and the row query run in the same read only transaction,
+	// so that both see the same snapshot of the table
+	tx, lerr := d.beginReadOnly(ctx)
+	if lerr != nil {
+		return nil, lerr
+	}
+
+	args := queryArgs(d.datasetDefinition, sinceValue(since), limit)
 	var maxSince, nextToken string
 	if sinceCol != "" {
 		// build max since query
-		maxSinceQuery := "SELECT MAX(" + sinceCol + ") AS \"_MAX_SINCE\" FROM " + d.datasetDefinition.SourceConfig[TableName].(string)
-		maxRow := db.QueryRowContext(ctx, maxSinceQuery)
+		from, _ := querySource(d.datasetDefinition)
+		maxSinceQuery := "SELECT MAX(" + sinceCol + ") AS \"_MAX_SINCE\" FROM " + from
+		maxRow := tx.QueryRowContext(ctx, maxSinceQuery, args...)
 		if maxRow == nil || maxRow.Err() != nil {
+			tx.Rollback()
 			d.logger.Error("failed to get max since", "error", maxRow.Err())
 			return nil, ErrQuery(maxRow.Err())
 		}
 		err := maxRow.Scan(&maxSince)
 		if err != nil {
+			tx.Rollback()
 			d.logger.Error("failed to scan max since", "error", err)
 			return nil, ErrQuery(err)
 		}
 
-		newSince := fmt.Sprintf("%v", maxSince)
-		nextToken = base64.URLEncoding.EncodeToString([]byte(newSince))
+		nextToken = encodeToken(fmt.Sprintf("%v", maxSince), scn)
 	}
 
 	// build the query
-	query, err := buildQuery(d.datasetDefinition, since, maxSince, limit)
+	query, sinceArgs, err := buildQuery(d.datasetDefinition, since, maxSince, limit, latestOnly)
 	d.logger.Debug(fmt.Sprintf("changes query for dataset %s: %s", d.Name(), query), "dataset", d.Name())
 	if err != nil {
+		tx.Rollback()
 		d.logger.Error("failed to build query", "error", err)
 		return nil, ErrQuery(err)
 	}
 
-	rows, err := db.QueryContext(ctx, query)
+	rows, err := tx.QueryContext(ctx, query, append(args, sinceArgs...)...)
 	if err != nil {
+		tx.Rollback()
 		d.logger.Error("failed to execute query", "error", err)
 		return nil, ErrQuery(err)
 	}
+	cts, columns, rowBuf, err := d.scanBuffer(rows)
+	if err != nil {
+		rows.Close()
+		tx.Rollback()
+		return nil, ErrQuery(err)
+	}
+	return &dbIterator{
+		logger:       d.logger,
+		since:        since,
+		limit:        limit,
+		mapper:       mapper,
+		tx:           tx,
+		rows:         rows,
+		currentToken: nextToken,
+		colTypes:     cts,
+		columns:      columns,
+		rowBuf:       rowBuf,
+		sinceColumn:  sinceCol,
+		deleted:      columnIndex(columns, deletedColumn(d.datasetDefinition)),
+	}, nil
+}
+
+// beginReadOnly starts a read only transaction, so that all queries in it see the same snapshot
+func (d *Dataset) beginReadOnly(ctx context.Context) (*sql.Tx, common.LayerError) {
+	tx, err := d.db.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, ErrConnection(err)
+	}
+	// go-ora does not support the ReadOnly flag of sql.TxOptions
+	_, err = tx.ExecContext(ctx, "SET TRANSACTION READ ONLY")
+	if err != nil {
+		tx.Rollback()
+		d.logger.Error("failed to start read only transaction", "error", err)
+		return nil, ErrQuery(err)
+	}
+	return tx, nil
+}
+
+// scanBuffer primes scan targets for the columns of rows
+func (d *Dataset) scanBuffer(rows *sql.Rows) ([]*sql.ColumnType, []string, []any, error) {
 	cts, err := rows.ColumnTypes()
 	if err != nil {
 		d.logger.Error("failed to get column types", "error", err)
-		return nil, ErrQuery(err)
+		return nil, nil, nil, err
 	}
 	columns, err := rows.Columns()
 	if err != nil {
 		d.logger.Error("failed to get columns", "error", err)
-		return nil, ErrQuery(err)
+		return nil, nil, nil, err
 	}
-	// primimg the rowBuf array with correct types for the scan
-	// since we are targeting json, we only need to support the types that can be represented in json
-	// namely string, number (float64), boolean
+	// priming the rowBuf array with scan targets for the column types
 	rowBuf := make([]any, 0, len(cts))
 	for _, ct := range cts {
-		// oracle NUMBER(1,0) is a commonly used as boolean, but in newer versions there is a BOOLEAN
-		// data type as well, which looks like NUMBER(38,255) to the driver.
-		// we cant be sure that it is meant to be a boolean, so we need to check the mapping for a type hint
 		var pm *common.ItemToEntityPropertyMapping
 		for _, propMapping := range d.datasetDefinition.OutgoingMappingConfig.PropertyMappings {
 			if strings.ToUpper(propMapping.Property) == ct.Name() {
@@ -91,51 +194,54 @@ func (d *Dataset) newIterator(mapper *common.Mapper, since string, limit int) (*
 				break
 			}
 		}
-		if ct.DatabaseTypeName() == "NUMBER" && pm != nil && (pm.IsDeleted || strings.HasPrefix(strings.ToUpper(pm.Datatype), "BOOL")) {
-			rowBuf = append(rowBuf, &sql.NullBool{})
-		} else {
-			st := ct.ScanType()
-			if st == nil {
-				d.logger.Error("no scan type for column", "column", ct.Name())
-				return nil, ErrQuery(fmt.Errorf("no scan type for column %s", ct.Name()))
-			}
-			ex := reflect.New(st).Interface()
-			switch ex.(type) {
-			case *bool:
-				rowBuf = append(rowBuf, &sql.NullBool{})
-			case *int64:
-				rowBuf = append(rowBuf, &sql.NullFloat64{})
-			case *float32:
-				rowBuf = append(rowBuf, &sql.NullFloat64{})
-			case *float64:
-				rowBuf = append(rowBuf, &sql.NullFloat64{})
-			default:
-				rowBuf = append(rowBuf, &sql.NullString{})
-			}
+		target, err := newScanTarget(ct, pm)
+		if err != nil {
+			d.logger.Error("no scan type for column", "column", ct.Name())
+			return nil, nil, nil, err
 		}
+		rowBuf = append(rowBuf, target)
+	}
+	if n := len(columns); n > 0 && "\""+columns[n-1]+"\"" == latestColumn {
+		// the version number of latest only queries is scanned, but not mapped
+		columns = columns[:n-1]
+	}
+	return cts, columns, rowBuf, nil
+}
 
+// sinceColumn returns the column used as watermark for incremental reads.
+// with ora_rowscn change tracking, this is the ORA_ROWSCN pseudo column, which reflects the
+// scn of the last change of a row (or its block, if the table is created without ROWDEPENDENCIES)
+func sinceColumn(definition *common.DatasetDefinition) string {
+	if definition.SourceConfig[ChangeTracking] == ChangeTrackingRowSCN {
+		return "ORA_ROWSCN"
 	}
+	sinceCol, _ := definition.SourceConfig[SinceColumn].(string)
+	return sinceCol
+}
 
-	return &dbIterator{
-		logger:       d.logger,
-		since:        since,
-		limit:        limit,
-		mapper:       mapper,
-		db:           db,
-		rows:         rows,
-		currentToken: nextToken,
-		colTypes:     cts,
-		columns:      columns,
-		rowBuf:       rowBuf,
-		sinceColumn:  sinceCol,
-	}, nil
+// checkRowDependencies makes sure the table tracks scns per row instead of per block.
+// without row dependencies, all rows in a changed block are emitted again
+func checkRowDependencies(ctx context.Context, db *sql.DB, table string) error {
+	owner, name := splitTableName(table)
+	var deps string
+	err := db.QueryRowContext(ctx,
+		"SELECT DEPENDENCIES FROM ALL_TABLES WHERE TABLE_NAME = :1 AND OWNER = NVL(:2, USER)", name, owner).Scan(&deps)
+	if err != nil {
+		return fmt.Errorf("failed to look up table %s: %w", table, err)
+	}
+	if deps != "ENABLED" {
+		return fmt.Errorf("table %s is not created with ROWDEPENDENCIES", table)
+	}
+	return nil
 }
 
-func buildQuery(definition *common.DatasetDefinition, since string, maxSince string, limit int) (string, error) {
-	sinceCol, _ := definition.SourceConfig[SinceColumn].(string)
+// buildQuery returns the changes query of a dataset and the arguments for its since bounds.
+// the bounds are bound as :since_from and :since_to, in addition to the arguments of a custom query
+func buildQuery(definition *common.DatasetDefinition, since string, maxSince string, limit int, latestOnly bool) (string, []any, error) {
+	sinceCol := sinceColumn(definition)
 	cols := "*"
 	if definition.OutgoingMappingConfig == nil {
-		return "", fmt.Errorf("outgoing mapping config is missing")
+		return "", nil, fmt.Errorf("outgoing mapping config is missing")
 	}
 	if !definition.OutgoingMappingConfig.MapAll {
 		cols = ""
@@ -145,44 +251,198 @@ func buildQuery(definition *common.DatasetDefinition, since string, maxSince str
 			}
 			cols = cols + pm.Property
 		}
+		// soft deleted rows are emitted as deleted entities, so the flag is needed even if it is not mapped
+		if col := deletedColumn(definition); col != "" && !containsColumn(strings.Split(cols, ", "), col) {
+			cols = cols + ", " + col
+		}
 	}
-	q := "SELECT " + cols + " FROM " + definition.SourceConfig[TableName].(string)
-
-	_, err := strconv.Atoi(maxSince)
-	if err != nil {
-		maxSince = fmt.Sprintf("'%s'", maxSince)
-	}
+	from, table := querySource(definition)
+	where := ""
 
+	// the since values come from the continuation token of the client, so they are bound, never inlined
+	var args []any
 	if sinceCol != "" {
+		args = append(args, sql.Named("since_to", sinceBindValue(maxSince)))
 		if since != "" {
-			sinceVal, err := base64.URLEncoding.DecodeString(since)
-			if err != nil {
-				return "", fmt.Errorf("failed to decode since token %s", since)
-			}
-			sinceValStr := string(sinceVal)
-			_, err = strconv.Atoi(sinceValStr)
+			sinceValStr, scn, err := decodeToken(since)
 			if err != nil {
-				sinceValStr = fmt.Sprintf("'%s'", sinceValStr)
+				return "", nil, err
 			}
+			args = append(args, sql.Named("since_from", sinceBindValue(sinceValStr)))
 
-			q += fmt.Sprintf(" WHERE %s.%s > %s AND %s.%s <= %s",
-				definition.SourceConfig[TableName], definition.SourceConfig[SinceColumn], sinceValStr,
-				definition.SourceConfig[TableName], definition.SourceConfig[SinceColumn], maxSince)
+			lowerBound := fmt.Sprintf("%s.%s > :since_from", table, sinceCol)
+			if scn > 0 {
+				// rows committed after the previous page was read are included, even if their
+				// since value is lower than the previous max value
+				lowerBound = fmt.Sprintf("(%s OR %s.ORA_ROWSCN > %d)", lowerBound, table, scn)
+			}
+			where = fmt.Sprintf(" WHERE %s AND %s.%s <= :since_to", lowerBound, table, sinceCol)
 		} else {
-			q += fmt.Sprintf(" WHERE %s.%s <= %s",
-				definition.SourceConfig[TableName], definition.SourceConfig[SinceColumn], maxSince)
+			where = fmt.Sprintf(" WHERE %s.%s <= :since_to", table, sinceCol)
 		}
 	}
+
+	var q string
+	if latestOnly {
+		latest, err := latestQuery(definition, from, table, where)
+		if err != nil {
+			return "", nil, err
+		}
+		q = "SELECT " + cols + " FROM (" + latest + ") WHERE " + latestColumn + " = 1"
+	} else {
+		q = "SELECT " + cols + " FROM " + from + where
+	}
 	if limit != 0 {
 		q += " FETCH FIRST " + strconv.Itoa(limit) + " ROWS ONLY"
 	}
-	return q, nil
+	return q, args, nil
+}
+
+// sinceBindValue binds numeric since values as numbers, like the unquoted literals of earlier
+// versions. other values are bound as text and converted by oracle to the column type
+func sinceBindValue(val string) any {
+	if n, err := strconv.ParseInt(val, 10, 64); err == nil {
+		return n
+	}
+	return val
+}
+
+// querySource returns the from clause of the dataset and the name to qualify its columns with.
+// a custom query is wrapped as subquery, aliased as table_name (or Q if there is no table_name)
+func querySource(definition *common.DatasetDefinition) (string, string) {
+	table, _ := definition.SourceConfig[TableName].(string)
+	if query, ok := definition.SourceConfig[Query].(string); ok && query != "" {
+		if table == "" {
+			table = "Q"
+		}
+		return "(" + query + ") " + table, table
+	}
+	return table, table
+}
+
+var queryPlaceholder = regexp.MustCompile(`(?i):(since|limit)\b`)
+
+// queryArgs binds the :since and :limit placeholders used in a custom query.
+// since is nil on the first page, limit is nil if there is no limit
+func queryArgs(definition *common.DatasetDefinition, since any, limit int) []any {
+	query, _ := definition.SourceConfig[Query].(string)
+	var args []any
+	bound := map[string]bool{}
+	for _, m := range queryPlaceholder.FindAllStringSubmatch(query, -1) {
+		name := strings.ToLower(m[1])
+		if bound[name] {
+			continue
+		}
+		bound[name] = true
+		switch name {
+		case "since":
+			args = append(args, sql.Named(name, since))
+		case "limit":
+			if limit > 0 {
+				args = append(args, sql.Named(name, limit))
+			} else {
+				args = append(args, sql.Named(name, nil))
+			}
+		}
+	}
+	return args
+}
+
+// sinceValue returns the watermark value of a since token, or nil
+func sinceValue(since string) any {
+	if since == "" {
+		return nil
+	}
+	val, _, err := decodeToken(since)
+	if err != nil {
+		return nil // reported by buildQuery
+	}
+	return val
+}
+
+// latestColumn is the row number of a row within the versions of its key, 1 being the latest version.
+// it is always the last column of a latest only query
+const latestColumn = "\"_LATEST\""
+
+// latestQuery numbers the versions of each key in a change log table, newest first.
+// the watermark conditions are applied before numbering. since the watermark grows with
+// every new version, the newest version of a changed key always is within the watermark range
+func latestQuery(definition *common.DatasetDefinition, from, table string, where string) (string, error) {
+	keys, lerr := keyColumns(definition)
+	if lerr != nil {
+		return "", lerr
+	}
+	recorded := recordedColumn(definition)
+	if recorded == "" {
+		return "", fmt.Errorf("no recorded column to order versions by in dataset %s", definition.DatasetName)
+	}
+	partition := ""
+	for i, k := range keys {
+		if i != 0 {
+			partition += ", "
+		}
+		partition += table + "." + k
+	}
+	return "SELECT " + table + ".*, ROW_NUMBER() OVER (PARTITION BY " + partition +
+		" ORDER BY " + table + "." + recorded + " DESC) AS " + latestColumn + " FROM " + from + where, nil
+}
+
+// isChangeLog tells if the dataset table contains multiple versions per key.
+// tables written in append mode with a recorded column are change logs unless declared otherwise
+func isChangeLog(definition *common.DatasetDefinition) bool {
+	if cl, ok := definition.SourceConfig[ChangeLog].(bool); ok {
+		return cl
+	}
+	return definition.SourceConfig[AppendMode] == true && recordedColumn(definition) != ""
+}
+
+// recordedColumn returns the column that orders the versions of a key in a change log table.
+// without recorded_column in source config, the recorded column of the outgoing mapping is used
+func recordedColumn(definition *common.DatasetDefinition) string {
+	if col, ok := definition.SourceConfig[RecordedColumn].(string); ok && col != "" {
+		return col
+	}
+	if definition.OutgoingMappingConfig != nil {
+		for _, pm := range definition.OutgoingMappingConfig.PropertyMappings {
+			if pm.IsRecorded {
+				return pm.Property
+			}
+		}
+	}
+	return ""
+}
+
+// encodeToken encodes a since value and an optional scn as continuation token.
+// the format is <base64 value>[.<scn>], the scn part is left out when scn is 0
+func encodeToken(value string, scn int64) string {
+	token := base64.URLEncoding.EncodeToString([]byte(value))
+	if scn > 0 {
+		token += "." + strconv.FormatInt(scn, 10)
+	}
+	return token
+}
+
+func decodeToken(token string) (string, int64, error) {
+	var scn int64
+	encoded, scnStr, found := strings.Cut(token, ".")
+	if found {
+		var err error
+		scn, err = strconv.ParseInt(scnStr, 10, 64)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to decode scn in since token %s", token)
+		}
+	}
+	val, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to decode since token %s", token)
+	}
+	return string(val), scn, nil
 }
 
 type dbIterator struct {
 	logger       common.Logger
 	mapper       *common.Mapper
-	db           *sql.DB
+	tx           *sql.Tx
 	rows         *sql.Rows
 	since        string
 	currentToken string
@@ -191,6 +451,10 @@ type dbIterator struct {
 	columns      []string
 	limit        int
 	sinceColumn  string
+	// keyset pagination of /entities
+	keys    []int // index of the key columns, nil for /changes
+	deleted int   // index of the deleted flag, -1 if there is none
+	count   int
 }
 
 func (it *dbIterator) Context() *egdm.Context {
@@ -199,12 +463,27 @@ func (it *dbIterator) Context() *egdm.Context {
 }
 
 func (it *dbIterator) Next() (*egdm.Entity, common.LayerError) {
-	if it.rows.Next() {
+	if it.keys != nil && it.limit > 0 && it.count >= it.limit {
+		return nil, nil // page is full
+	}
+	for it.rows.Next() {
 		err := it.rows.Scan(it.rowBuf...)
 		if err != nil {
 			it.logger.Error("failed to scan row", "error", err)
 			return nil, common.Err(err, common.LayerErrorInternal)
 		}
+		deleted := it.deleted >= 0 && flagSet(it.rowBuf[it.deleted])
+		if it.keys != nil {
+			if deleted {
+				continue // deleted rows are not part of the current state
+			}
+			key := make([]any, len(it.keys))
+			for i, k := range it.keys {
+				key[i] = keyValue(it.rowBuf[k])
+			}
+			it.currentToken = encodeKeyToken(key)
+			it.count++
+		}
 
 		entity := egdm.NewEntity()
 		ri := &RowItem{
@@ -221,17 +500,19 @@ func (it *dbIterator) Next() (*egdm.Entity, common.LayerError) {
 			it.logger.Error("failed to map row", "error", err, "row", fmt.Sprintf("%+v", ri))
 			return nil, common.Err(err, common.LayerErrorInternal)
 		}
+		if deleted {
+			// soft deleted rows are emitted as deleted entities, even if the flag column is not mapped
+			entity.IsDeleted = true
+		}
 
 		return entity, nil
-
-	} else {
-		// exhausted or failed
-		if it.rows.Err() != nil {
-			it.logger.Error("failed to read rows", "error", it.rows.Err())
-			return nil, common.Err(it.rows.Err(), common.LayerErrorInternal)
-		}
-		return nil, nil // end of result set
 	}
+	// exhausted or failed
+	if it.rows.Err() != nil {
+		it.logger.Error("failed to read rows", "error", it.rows.Err())
+		return nil, common.Err(it.rows.Err(), common.LayerErrorInternal)
+	}
+	return nil, nil // end of result set
 }
 
 func (it *dbIterator) Token() (*egdm.Continuation, common.LayerError) {
@@ -249,9 +530,11 @@ func (it *dbIterator) Token() (*egdm.Continuation, common.LayerError) {
 func (it *dbIterator) Close() common.LayerError {
 	err := it.rows.Close()
 	if err != nil {
+		it.tx.Rollback()
 		return common.Err(err, common.LayerErrorInternal)
 	}
-	err = it.db.Close()
+	// the transaction is read only, nothing to commit
+	err = it.tx.Rollback()
 	if err != nil {
 		return ErrConnection(err)
 	}
@@ -0,0 +1,195 @@
+package layer
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+
+	common "github.com/mimiro-io/common-datalayer"
+)
+
+func testReadDefinition(sourceConfig map[string]any) *common.DatasetDefinition {
+	return &common.DatasetDefinition{
+		DatasetName:  "test",
+		SourceConfig: sourceConfig,
+		OutgoingMappingConfig: &common.OutgoingMappingConfig{
+			PropertyMappings: []*common.ItemToEntityPropertyMapping{
+				{Property: "ID", IsIdentity: true},
+				{Property: "NAME"},
+			},
+		},
+	}
+}
+
+func TestContinuationToken(t *testing.T) {
+	t.Run("should round-trip value without scn", func(t *testing.T) {
+		token := encodeToken("164565574", 0)
+		if strings.Contains(token, ".") {
+			t.Fatalf("expected plain base64 token, got %s", token)
+		}
+		val, scn, err := decodeToken(token)
+		if err != nil || val != "164565574" || scn != 0 {
+			t.Fatalf("unexpected decode result: %v, %v, %v", val, scn, err)
+		}
+	})
+	t.Run("should round-trip value with scn", func(t *testing.T) {
+		val, scn, err := decodeToken(encodeToken("2024-01-01 10:00:00", 4711))
+		if err != nil || val != "2024-01-01 10:00:00" || scn != 4711 {
+			t.Fatalf("unexpected decode result: %v, %v, %v", val, scn, err)
+		}
+	})
+	t.Run("should reject invalid tokens", func(t *testing.T) {
+		if _, _, err := decodeToken("ACD45FB"); err == nil {
+			t.Fatal("expected error for invalid base64")
+		}
+		if _, _, err := decodeToken(encodeToken("1", 0) + ".x"); err == nil {
+			t.Fatal("expected error for invalid scn")
+		}
+	})
+}
+
+func TestBuildQuery(t *testing.T) {
+	t.Run("should bound query by previous and max since value", func(t *testing.T) {
+		def := testReadDefinition(map[string]any{TableName: "sample", SinceColumn: "recorded"})
+		q, args, err := buildQuery(def, encodeToken("10", 0), "20", 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q != "SELECT ID, NAME FROM sample WHERE sample.recorded > :since_from AND sample.recorded <= :since_to" {
+			t.Fatalf("unexpected query: %s", q)
+		}
+		expected := []any{sql.Named("since_to", int64(20)), sql.Named("since_from", int64(10))}
+		if !reflect.DeepEqual(args, expected) {
+			t.Fatalf("unexpected args: %v", args)
+		}
+	})
+	t.Run("should bind since values from the token", func(t *testing.T) {
+		def := testReadDefinition(map[string]any{TableName: "sample", SinceColumn: "recorded"})
+		injected := "x' OR '1'='1"
+		q, args, err := buildQuery(def, encodeToken(injected, 0), "2024-01-01", 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(q, injected) {
+			t.Fatalf("since value should not be part of the query: %s", q)
+		}
+		expected := []any{sql.Named("since_to", "2024-01-01"), sql.Named("since_from", injected)}
+		if !reflect.DeepEqual(args, expected) {
+			t.Fatalf("unexpected args: %v", args)
+		}
+	})
+	t.Run("should include rows committed after previous scn", func(t *testing.T) {
+		def := testReadDefinition(map[string]any{TableName: "sample", SinceColumn: "recorded", SnapshotSCN: true})
+		q, _, err := buildQuery(def, encodeToken("10", 4711), "20", 5, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "SELECT ID, NAME FROM sample WHERE (sample.recorded > :since_from OR sample.ORA_ROWSCN > 4711) " +
+			"AND sample.recorded <= :since_to FETCH FIRST 5 ROWS ONLY"
+		if q != expected {
+			t.Fatalf("unexpected query: %s", q)
+		}
+	})
+	t.Run("should use ORA_ROWSCN as watermark with ora_rowscn change tracking", func(t *testing.T) {
+		def := testReadDefinition(map[string]any{TableName: "sample", ChangeTracking: ChangeTrackingRowSCN})
+		q, _, err := buildQuery(def, encodeToken("4711", 0), "4800", 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q != "SELECT ID, NAME FROM sample WHERE sample.ORA_ROWSCN > :since_from AND sample.ORA_ROWSCN <= :since_to" {
+			t.Fatalf("unexpected query: %s", q)
+		}
+		q, args, err := buildQuery(def, "", "4800", 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q != "SELECT ID, NAME FROM sample WHERE sample.ORA_ROWSCN <= :since_to" {
+			t.Fatalf("unexpected query: %s", q)
+		}
+		if !reflect.DeepEqual(args, []any{sql.Named("since_to", int64(4800))}) {
+			t.Fatalf("unexpected args: %v", args)
+		}
+	})
+	t.Run("should select latest version per key in change log", func(t *testing.T) {
+		def := testReadDefinition(map[string]any{TableName: "sample", SinceColumn: "recorded", AppendMode: true})
+		def.OutgoingMappingConfig.PropertyMappings = append(def.OutgoingMappingConfig.PropertyMappings,
+			&common.ItemToEntityPropertyMapping{Property: "RECORDED", IsRecorded: true})
+		q, _, err := buildQuery(def, encodeToken("10", 0), "20", 5, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "SELECT ID, NAME, RECORDED FROM (SELECT sample.*, ROW_NUMBER() OVER (PARTITION BY sample.ID " +
+			"ORDER BY sample.RECORDED DESC) AS \"_LATEST\" FROM sample WHERE sample.recorded > :since_from AND sample.recorded <= :since_to) " +
+			"WHERE \"_LATEST\" = 1 FETCH FIRST 5 ROWS ONLY"
+		if q != expected {
+			t.Fatalf("unexpected query: %s", q)
+		}
+	})
+	t.Run("should require recorded column for latest only", func(t *testing.T) {
+		def := testReadDefinition(map[string]any{TableName: "sample", ChangeLog: true, KeyColumns: []any{"TENANT", "ID"}})
+		if _, _, err := buildQuery(def, "", "", 0, true); err == nil {
+			t.Fatal("expected error without recorded column")
+		}
+		def.SourceConfig[RecordedColumn] = "VERSION"
+		q, _, err := buildQuery(def, "", "", 0, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(q, "PARTITION BY sample.TENANT, sample.ID ORDER BY sample.VERSION DESC") {
+			t.Fatalf("unexpected query: %s", q)
+		}
+	})
+}
+
+func TestIsChangeLog(t *testing.T) {
+	if isChangeLog(testReadDefinition(map[string]any{TableName: "sample"})) {
+		t.Fatal("plain table should not be a change log")
+	}
+	if isChangeLog(testReadDefinition(map[string]any{TableName: "sample", AppendMode: true})) {
+		t.Fatal("append mode table without recorded column should not be a change log")
+	}
+	if !isChangeLog(testReadDefinition(map[string]any{TableName: "sample", AppendMode: true, RecordedColumn: "RECORDED"})) {
+		t.Fatal("append mode table should be a change log")
+	}
+	if isChangeLog(testReadDefinition(map[string]any{TableName: "sample", AppendMode: true, RecordedColumn: "RECORDED", ChangeLog: false})) {
+		t.Fatal("change_log should override append mode")
+	}
+}
+
+func TestCustomQuery(t *testing.T) {
+	query := "SELECT o.id, o.recorded, c.name FROM orders o JOIN customers c ON o.customer = c.id WHERE o.recorded > NVL(:since, 0)"
+	t.Run("should wrap query as subquery", func(t *testing.T) {
+		def := testReadDefinition(map[string]any{Query: query, SinceColumn: "RECORDED"})
+		q, _, err := buildQuery(def, encodeToken("10", 0), "20", 5, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "SELECT ID, NAME FROM (" + query + ") Q WHERE Q.RECORDED > :since_from AND Q.RECORDED <= :since_to FETCH FIRST 5 ROWS ONLY"
+		if q != expected {
+			t.Fatalf("unexpected query: %s", q)
+		}
+	})
+	t.Run("should alias subquery as table_name", func(t *testing.T) {
+		def := testReadDefinition(map[string]any{Query: query, TableName: "orders"})
+		from, alias := querySource(def)
+		if from != "("+query+") orders" || alias != "orders" {
+			t.Fatalf("unexpected source: %s, %s", from, alias)
+		}
+	})
+	t.Run("should bind placeholders used in query", func(t *testing.T) {
+		def := testReadDefinition(map[string]any{Query: query})
+		args := queryArgs(def, sinceValue(encodeToken("10", 0)), 5)
+		if !reflect.DeepEqual(args, []any{sql.Named("since", "10")}) {
+			t.Fatalf("unexpected args: %v", args)
+		}
+		def.SourceConfig[Query] = "SELECT * FROM t WHERE v > :SINCE OR :since IS NULL FETCH FIRST :limit ROWS ONLY"
+		args = queryArgs(def, nil, 0)
+		if !reflect.DeepEqual(args, []any{sql.Named("since", nil), sql.Named("limit", nil)}) {
+			t.Fatalf("unexpected args: %v", args)
+		}
+		if args = queryArgs(testReadDefinition(map[string]any{TableName: "sample"}), "10", 5); len(args) != 0 {
+			t.Fatalf("expected no args for table dataset, got %v", args)
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package layer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	common "github.com/mimiro-io/common-datalayer"
+	egdm "github.com/mimiro-io/entity-graph-data-model"
+	go_ora "github.com/sijms/go-ora/v2"
+)
+
+// error codes of rejected entities. database errors are reported with their ORA- code if there is one
+const (
+	rejectMapping    = "MAPPING"
+	rejectConversion = "CONVERSION"
+	rejectDatabase   = "DATABASE"
+)
+
+// rejectColumns are the columns of a reject table, in the order they are written
+var rejectColumns = []string{"DATASET", "ENTITY_ID", "ERROR_CODE", "ERROR_MESSAGE", "ENTITY", "REJECTED_AT"}
+
+// rejectFileLock serializes appends to reject files, which can be shared by datasets
+var rejectFileLock sync.Mutex
+
+var oraCode = regexp.MustCompile(`ORA-\d{5}`)
+
+// rejection describes where entities that can not be written are kept with error policy reject
+type rejection struct {
+	table string // reject table, written in the transaction of the writer
+	file  string // local NDJSON file
+}
+
+// rejectionOf reads error_policy and the reject destination of a dataset definition.
+// with the default error policy fail, the returned rejection is disabled
+func rejectionOf(definition *common.DatasetDefinition) (rejection, error) {
+	var r rejection
+	policy, _ := definition.SourceConfig[ErrorPolicy].(string)
+	switch policy {
+	case "", ErrorPolicyFail:
+		return r, nil
+	case ErrorPolicyReject:
+	default:
+		return r, fmt.Errorf("%s must be %s or %s", ErrorPolicy, ErrorPolicyFail, ErrorPolicyReject)
+	}
+	r.table, _ = definition.SourceConfig[RejectTable].(string)
+	r.file, _ = definition.SourceConfig[RejectFile].(string)
+	if (r.table == "") == (r.file == "") {
+		return r, fmt.Errorf("%s %s requires either %s or %s", ErrorPolicy, ErrorPolicyReject, RejectTable, RejectFile)
+	}
+	return r, nil
+}
+
+func (r rejection) enabled() bool {
+	return r.table != "" || r.file != ""
+}
+
+// rejectRecord is one rejected entity, as written to the reject table or file
+type rejectRecord struct {
+	Dataset    string          `json:"dataset"`
+	EntityID   string          `json:"entity_id"`
+	ErrorCode  string          `json:"error_code"`
+	Message    string          `json:"error_message"`
+	Entity     json.RawMessage `json:"entity"`
+	RejectedAt time.Time       `json:"rejected_at"`
+}
+
+// write stores a rejected entity. exec is used for the reject table, so that rejects are
+// committed together with the accepted rows
+func (r rejection) write(ctx context.Context, exec func(context.Context, string, ...any) (sql.Result, error), rec rejectRecord) error {
+	if r.file != "" {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		rejectFileLock.Lock()
+		defer rejectFileLock.Unlock()
+		f, err := os.OpenFile(r.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		if _, err = f.Write(append(line, '\n')); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	_, err := exec(ctx, rejectStatement(r.table), rec.Dataset, rec.EntityID, rec.ErrorCode,
+		truncate(rec.Message, 4000), go_ora.Clob{String: string(rec.Entity), Valid: true}, rec.RejectedAt)
+	return err
+}
+
+func rejectStatement(table string) string {
+	var sb strings.Builder
+	sb.WriteString("INSERT INTO ")
+	sb.WriteString(quoteTable(table))
+	sb.WriteString(" (")
+	for i, col := range rejectColumns {
+		if i != 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(quoteIdent(col))
+	}
+	sb.WriteString(") VALUES (")
+	for i := range rejectColumns {
+		if i != 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(placeholder(i + 1))
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+// newRejectRecord describes why an entity was rejected. the error code is the first ORA- code
+// in the error, or code if there is none
+func newRejectRecord(dataset string, entity *egdm.Entity, code string, err error) rejectRecord {
+	if c := oraCode.FindString(err.Error()); c != "" {
+		code = c
+	}
+	payload, jerr := json.Marshal(entity)
+	if jerr != nil {
+		payload, _ = json.Marshal(fmt.Sprintf("%v", entity))
+	}
+	return rejectRecord{
+		Dataset:    dataset,
+		EntityID:   entity.ID,
+		ErrorCode:  code,
+		Message:    err.Error(),
+		Entity:     payload,
+		RejectedAt: time.Now(),
+	}
+}
+
+// truncate shortens s to at most n bytes, without splitting a character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && n < len(s) && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
+
+// reject writes an entity that can not be written to the reject destination and counts it
+func (o *OracleWriter) reject(entity *egdm.Entity, code string, err error) common.LayerError {
+	rec := newRejectRecord(o.dataset, entity, code, err)
+	o.logger.Warn("rejected entity", "dataset", o.dataset, "entity", entity.ID, "code", rec.ErrorCode, "error", err)
+	if werr := o.rejection.write(o.ctx, o.exec, rec); werr != nil {
+		return common.Errorf(common.LayerErrorInternal, "failed to reject entity %s. %w, rejected because of: %w", entity.ID, werr, err)
+	}
+	o.rejected++
+	return nil
+}
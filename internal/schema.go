@@ -0,0 +1,249 @@
+package layer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	common "github.com/mimiro-io/common-datalayer"
+)
+
+// ensureSchema creates the dataset table if create_table is enabled and the table does not exist,
+// and adds missing mapped columns if evolve_schema is enabled. the soft delete columns of del are
+// handled like mapped columns. columns are the existing columns of the table, the returned map
+// contains the columns after the change.
+func (d *Dataset) ensureSchema(ctx context.Context, db *sql.DB, table string, columns map[string]tableColumn,
+	keyCols []string, appendMode bool, del deletion,
+) (map[string]tableColumn, error) {
+	def := d.datasetDefinition
+	if def.IncomingMappingConfig == nil {
+		return columns, nil
+	}
+	mappings := del.schemaMappings(def.IncomingMappingConfig.PropertyMappings)
+	var stmt string
+	if len(columns) == 0 && def.SourceConfig[CreateTable] == true {
+		stmt = createTableStatement(table, mappings, keyCols, appendMode)
+	} else if len(columns) > 0 && def.SourceConfig[EvolveSchema] == true {
+		stmt = addColumnsStatement(table, mappings, columns)
+	}
+	if stmt == "" {
+		return columns, nil
+	}
+	d.logger.Info(fmt.Sprintf("changing schema of dataset %s: %s", d.Name(), stmt), "dataset", d.Name())
+	_, err := db.ExecContext(ctx, stmt)
+	// another writer may have changed the table in the meantime
+	if err != nil && !strings.Contains(err.Error(), "ORA-00955") && // name is already used by an existing object
+		!strings.Contains(err.Error(), "ORA-01430") { // column being added already exists in table
+		return nil, err
+	}
+	d.invalidateSchema()
+	return tableColumns(ctx, db, table)
+}
+
+// createTableStatement creates a table with a column for each incoming property mapping.
+// in upsert mode, the key columns are the primary key of the table. append mode tables contain
+// multiple versions per key, so they have no key constraint.
+func createTableStatement(table string, mappings []*common.EntityToItemPropertyMapping, keyCols []string, appendMode bool) string {
+	var defs []string
+	var seen []string
+	for _, pm := range mappings {
+		if containsColumn(seen, pm.Property) {
+			continue
+		}
+		seen = append(seen, pm.Property)
+		col := quoteIdent(pm.Property) + " " + columnDataType(pm)
+		if !appendMode && containsColumn(keyCols, pm.Property) {
+			col += " NOT NULL"
+		}
+		defs = append(defs, col)
+	}
+	if !appendMode {
+		keys := make([]string, len(keyCols))
+		for i, k := range keyCols {
+			keys[i] = quoteIdent(k)
+		}
+		defs = append(defs, "PRIMARY KEY ("+strings.Join(keys, ", ")+")")
+	}
+	return "CREATE TABLE " + quoteTable(table) + " (" + strings.Join(defs, ", ") + ")"
+}
+
+// addColumnsStatement adds nullable columns for incoming property mappings that are not in the table.
+// it returns an empty string if all mapped columns exist
+func addColumnsStatement(table string, mappings []*common.EntityToItemPropertyMapping, columns map[string]tableColumn) string {
+	var defs []string
+	var seen []string
+	for _, pm := range mappings {
+		if _, ok := columns[strings.ToUpper(pm.Property)]; ok || containsColumn(seen, pm.Property) {
+			continue
+		}
+		seen = append(seen, pm.Property)
+		defs = append(defs, quoteIdent(pm.Property)+" "+columnDataType(pm))
+	}
+	if len(defs) == 0 {
+		return ""
+	}
+	return "ALTER TABLE " + quoteTable(table) + " ADD (" + strings.Join(defs, ", ") + ")"
+}
+
+// columnDataType derives the oracle type of a column from the property mapping and its datatype hint.
+// booleans are stored as NUMBER(1), which is supported by all oracle versions
+func columnDataType(pm *common.EntityToItemPropertyMapping) string {
+	switch {
+	case pm.IsIdentity, pm.IsReference:
+		return "VARCHAR2(1000)"
+	case pm.IsDeleted:
+		return "NUMBER(1)"
+	case pm.IsRecorded:
+		// the recorded time of entities is written as unix nano seconds
+		return "NUMBER(19)"
+	}
+	switch strings.ToLower(pm.Datatype) {
+	case "int", "integer", "long":
+		return "NUMBER(19)"
+	case "float", "double", "decimal", "number":
+		return "NUMBER"
+	case "bool", "boolean":
+		return "NUMBER(1)"
+	case "date", "datetime", "timestamp":
+		return "TIMESTAMP WITH TIME ZONE"
+	case "bytes", "binary":
+		return "BLOB"
+	case "text", "clob":
+		return "CLOB"
+	}
+	return "VARCHAR2(4000)"
+}
+
+// tableSchema describes the table of a dataset, as reported in the dataset metadata
+type tableSchema struct {
+	Table       string         `json:"table"`
+	Columns     []schemaColumn `json:"columns"`
+	PrimaryKey  []string       `json:"primary_key"`
+	Indexes     []schemaIndex  `json:"indexes"`
+	RowEstimate *int64         `json:"row_estimate"` // number of rows at the last statistics run, if any
+	// drift between mapping config and table
+	MissingColumns  []string `json:"missing_columns"`  // mapped columns that are not in the table
+	UnmappedColumns []string `json:"unmapped_columns"` // table columns that are not mapped
+}
+
+type schemaColumn struct {
+	Name      string `json:"name"`
+	DataType  string `json:"data_type"`
+	Nullable  bool   `json:"nullable"`
+	Length    int    `json:"length,omitempty"`
+	Precision *int64 `json:"precision,omitempty"`
+	Scale     *int64 `json:"scale,omitempty"`
+}
+
+type schemaIndex struct {
+	Name    string   `json:"name"`
+	Unique  bool     `json:"unique"`
+	Columns []string `json:"columns"`
+}
+
+// describeTable reads columns, primary key, indexes and the row estimate of a table from the data dictionary
+func describeTable(ctx context.Context, db *sql.DB, table string) (*tableSchema, error) {
+	columns, err := tableColumns(ctx, db, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s not found", table)
+	}
+	owner, name := splitTableName(table)
+	schema := &tableSchema{Table: name}
+	for _, c := range columns {
+		sc := schemaColumn{Name: c.Name, DataType: c.DataType, Nullable: c.Nullable, Length: c.Length}
+		if c.Precision.Valid {
+			sc.Precision = &c.Precision.Int64
+		}
+		if c.Scale.Valid {
+			sc.Scale = &c.Scale.Int64
+		}
+		schema.Columns = append(schema.Columns, sc)
+	}
+	sort.Slice(schema.Columns, func(i, j int) bool {
+		return columns[strings.ToUpper(schema.Columns[i].Name)].Position < columns[strings.ToUpper(schema.Columns[j].Name)].Position
+	})
+
+	rows, err := db.QueryContext(ctx, "SELECT cc.COLUMN_NAME FROM ALL_CONSTRAINTS c "+
+		"JOIN ALL_CONS_COLUMNS cc ON cc.OWNER = c.OWNER AND cc.CONSTRAINT_NAME = c.CONSTRAINT_NAME "+
+		"WHERE c.CONSTRAINT_TYPE = 'P' AND c.TABLE_NAME = :1 AND c.OWNER = NVL(:2, USER) ORDER BY cc.POSITION", name, owner)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var col string
+		if err = rows.Scan(&col); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		schema.PrimaryKey = append(schema.PrimaryKey, col)
+	}
+	rows.Close()
+
+	rows, err = db.QueryContext(ctx, "SELECT i.INDEX_NAME, i.UNIQUENESS, ic.COLUMN_NAME FROM ALL_INDEXES i "+
+		"JOIN ALL_IND_COLUMNS ic ON ic.INDEX_OWNER = i.OWNER AND ic.INDEX_NAME = i.INDEX_NAME "+
+		"WHERE i.TABLE_NAME = :1 AND i.TABLE_OWNER = NVL(:2, USER) ORDER BY i.INDEX_NAME, ic.COLUMN_POSITION", name, owner)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var index, uniqueness, col string
+		if err = rows.Scan(&index, &uniqueness, &col); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if n := len(schema.Indexes); n == 0 || schema.Indexes[n-1].Name != index {
+			schema.Indexes = append(schema.Indexes, schemaIndex{Name: index, Unique: uniqueness == "UNIQUE"})
+		}
+		last := &schema.Indexes[len(schema.Indexes)-1]
+		last.Columns = append(last.Columns, col)
+	}
+	rows.Close()
+
+	var numRows sql.NullInt64
+	err = db.QueryRowContext(ctx, "SELECT NUM_ROWS FROM ALL_TABLES WHERE TABLE_NAME = :1 AND OWNER = NVL(:2, USER)",
+		name, owner).Scan(&numRows)
+	if err != nil && err != sql.ErrNoRows { // views are not in ALL_TABLES
+		return nil, err
+	}
+	if numRows.Valid {
+		schema.RowEstimate = &numRows.Int64
+	}
+	return schema, nil
+}
+
+// compareMappings records the mapped columns missing in the table, and the table columns
+// that are neither mapped in the incoming nor in the outgoing mapping
+func (s *tableSchema) compareMappings(definition *common.DatasetDefinition) {
+	var mapped []string
+	mapAll := false
+	if definition.IncomingMappingConfig != nil {
+		for _, pm := range definition.IncomingMappingConfig.PropertyMappings {
+			mapped = append(mapped, pm.Property)
+		}
+	}
+	if definition.OutgoingMappingConfig != nil {
+		mapAll = definition.OutgoingMappingConfig.MapAll
+		for _, pm := range definition.OutgoingMappingConfig.PropertyMappings {
+			mapped = append(mapped, pm.Property)
+		}
+	}
+	var cols []string
+	for _, c := range s.Columns {
+		cols = append(cols, c.Name)
+		if !mapAll && !containsColumn(mapped, c.Name) {
+			s.UnmappedColumns = append(s.UnmappedColumns, c.Name)
+		}
+	}
+	var seen []string
+	for _, m := range mapped {
+		if !containsColumn(cols, m) && !containsColumn(seen, m) && !strings.EqualFold(m, "ROWID") {
+			s.MissingColumns = append(s.MissingColumns, m)
+		}
+		seen = append(seen, m)
+	}
+}
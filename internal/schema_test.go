@@ -0,0 +1,97 @@
+package layer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	common "github.com/mimiro-io/common-datalayer"
+)
+
+func TestSchemaStatements(t *testing.T) {
+	mappings := []*common.EntityToItemPropertyMapping{
+		{Property: "id", IsIdentity: true},
+		{EntityProperty: "name", Property: "name"},
+		{EntityProperty: "age", Property: "age", Datatype: "int"},
+		{EntityProperty: "active", Property: "active", Datatype: "bool"},
+		{Property: "deleted", IsDeleted: true},
+		{Property: "recorded", IsRecorded: true},
+	}
+	t.Run("should create table with primary key in upsert mode", func(t *testing.T) {
+		stmt := createTableStatement("sample", mappings, []string{"id"}, false)
+		expected := `CREATE TABLE "SAMPLE" ("ID" VARCHAR2(1000) NOT NULL, "NAME" VARCHAR2(4000), "AGE" NUMBER(19), ` +
+			`"ACTIVE" NUMBER(1), "DELETED" NUMBER(1), "RECORDED" NUMBER(19), PRIMARY KEY ("ID"))`
+		if stmt != expected {
+			t.Fatalf("unexpected statement: %s", stmt)
+		}
+	})
+	t.Run("should create table without key constraint in append mode", func(t *testing.T) {
+		stmt := createTableStatement("sample", mappings[:2], []string{"id"}, true)
+		if stmt != `CREATE TABLE "SAMPLE" ("ID" VARCHAR2(1000), "NAME" VARCHAR2(4000))` {
+			t.Fatalf("unexpected statement: %s", stmt)
+		}
+	})
+	t.Run("should add missing columns only", func(t *testing.T) {
+		columns := map[string]tableColumn{
+			"ID": {Name: "ID", DataType: "VARCHAR2"}, "NAME": {Name: "NAME", DataType: "VARCHAR2"},
+			"AGE": {Name: "AGE", DataType: "NUMBER"}, "DELETED": {Name: "DELETED", DataType: "NUMBER"},
+		}
+		stmt := addColumnsStatement("sample", mappings, columns)
+		if stmt != `ALTER TABLE "SAMPLE" ADD ("ACTIVE" NUMBER(1), "RECORDED" NUMBER(19))` {
+			t.Fatalf("unexpected statement: %s", stmt)
+		}
+		columns["ACTIVE"] = tableColumn{Name: "ACTIVE"}
+		columns["RECORDED"] = tableColumn{Name: "RECORDED"}
+		if stmt = addColumnsStatement("sample", mappings, columns); stmt != "" {
+			t.Fatalf("expected no statement, got %s", stmt)
+		}
+	})
+	t.Run("should add soft delete columns", func(t *testing.T) {
+		del := deletion{mode: DeleteSoft, column: "removed", atColumn: "removed_at"}
+		stmt := createTableStatement("sample", del.schemaMappings(mappings[:2]), []string{"id"}, true)
+		if stmt != `CREATE TABLE "SAMPLE" ("ID" VARCHAR2(1000), "NAME" VARCHAR2(4000), "REMOVED" NUMBER(1), "REMOVED_AT" TIMESTAMP WITH TIME ZONE)` {
+			t.Fatalf("unexpected statement: %s", stmt)
+		}
+		columns := map[string]tableColumn{"ID": {Name: "ID"}, "NAME": {Name: "NAME"}, "REMOVED": {Name: "REMOVED"}}
+		stmt = addColumnsStatement("sample", del.schemaMappings(mappings[:2]), columns)
+		if stmt != `ALTER TABLE "SAMPLE" ADD ("REMOVED_AT" TIMESTAMP WITH TIME ZONE)` {
+			t.Fatalf("unexpected statement: %s", stmt)
+		}
+		del = deletion{mode: DeleteSoft, column: "deleted"}
+		if got := del.schemaMappings(mappings); len(got) != len(mappings) {
+			t.Fatalf("expected mapped deleted column not to be added again, got %d mappings", len(got))
+		}
+	})
+}
+
+func TestCompareMappings(t *testing.T) {
+	schema := &tableSchema{Columns: []schemaColumn{{Name: "ID"}, {Name: "NAME"}, {Name: "AGE"}}}
+	schema.compareMappings(&common.DatasetDefinition{
+		IncomingMappingConfig: &common.IncomingMappingConfig{PropertyMappings: []*common.EntityToItemPropertyMapping{
+			{Property: "id", IsIdentity: true}, {Property: "name"}, {Property: "weight"},
+		}},
+		OutgoingMappingConfig: &common.OutgoingMappingConfig{PropertyMappings: []*common.ItemToEntityPropertyMapping{
+			{Property: "ID", IsIdentity: true}, {Property: "ROWID"}, {Property: "WEIGHT"},
+		}},
+	})
+	if !reflect.DeepEqual(schema.MissingColumns, []string{"weight"}) {
+		t.Fatalf("unexpected missing columns: %v", schema.MissingColumns)
+	}
+	if !reflect.DeepEqual(schema.UnmappedColumns, []string{"AGE"}) {
+		t.Fatalf("unexpected unmapped columns: %v", schema.UnmappedColumns)
+	}
+}
+
+func TestSchemaCache(t *testing.T) {
+	cached := &tableSchema{Table: "TEST"}
+	ds := &Dataset{logger: common.NewLogger("test", "text", "error"), db: &oracleDB{}, datasetDefinition: testDefinition(nil)}
+	ds.schema, ds.schemaAt = cached, time.Now()
+	// the connection has no pool, so the metadata must be served from the cache
+	if md := ds.MetaData(); md["schema"] != cached {
+		t.Fatalf("expected cached schema, got %v", md)
+	}
+	ds.invalidateSchema()
+	if ds.schema != nil || !ds.schemaAt.IsZero() {
+		t.Fatalf("expected schema cache to be cleared")
+	}
+}
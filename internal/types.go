@@ -0,0 +1,170 @@
+package layer
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	common "github.com/mimiro-io/common-datalayer"
+)
+
+// columnValue is a scan target that converts the scanned driver value to the go value handed to the mapper
+type columnValue interface {
+	sql.Scanner
+	value() any
+}
+
+// newScanTarget returns the scan target for a result column, based on the oracle type of the column
+// and the outgoing property mapping of the column, if any
+func newScanTarget(ct *sql.ColumnType, pm *common.ItemToEntityPropertyMapping) (any, error) {
+	if target := scanTargetOf(ct.DatabaseTypeName(), pm); target != nil {
+		return target, nil
+	}
+	if ct.ScanType() == nil {
+		return nil, fmt.Errorf("no scan type for column %s of type %s", ct.Name(), ct.DatabaseTypeName())
+	}
+	// text types, CLOB, NCLOB, LONG, ROWID and INTERVAL types are read as text.
+	return &sql.NullString{}, nil
+}
+
+// scanTargetOf returns the scan target for the type name the driver reports for a column (the name
+// of the go-ora TNSType), or nil if the column is read as text
+func scanTargetOf(typeName string, pm *common.ItemToEntityPropertyMapping) sql.Scanner {
+	hint := ""
+	if pm != nil {
+		hint = strings.ToLower(pm.Datatype)
+	}
+	switch typeName {
+	case "NUMBER":
+		// oracle NUMBER(1,0) is a commonly used as boolean, but in newer versions there is a BOOLEAN
+		// data type as well, which looks like NUMBER(38,255) to the driver.
+		// we cant be sure that it is meant to be a boolean, so we need to check the mapping for a type hint
+		if pm != nil && (pm.IsDeleted || strings.HasPrefix(hint, "bool")) {
+			return &sql.NullBool{}
+		}
+		return &numberValue{}
+	case "TNSType(252)": // native BOOLEAN
+		return &sql.NullBool{}
+	case "IBFloat", "IBDouble", "BFloat", "BDouble":
+		return &sql.NullFloat64{}
+	case "DATE", "TIMESTAMP", "TimeStampDTY", "TimeStampTZ", "TimeStampTZ_DTY", "TimeStampeLTZ", "TimeStampLTZ_DTY":
+		// recorded timestamps are emitted as unix nano seconds
+		return &timeValue{unix: pm != nil && pm.IsRecorded}
+	case "RAW", "LongRaw", "OCIBlobLocator":
+		return &bytesValue{}
+	case "TNSType(119)": // native JSON
+		return &jsonValue{}
+	}
+	return nil
+}
+
+// numberValue reads NUMBER columns. the driver returns numbers as exact decimal text. integers are
+// returned as int64 and decimals as float64 if that is possible without loss of precision, otherwise
+// the decimal text is returned.
+type numberValue struct {
+	sql.NullString
+}
+
+// maxExactDigits is the number of significant decimal digits that survive a round trip through float64
+const maxExactDigits = 15
+
+func (n *numberValue) value() any {
+	if !n.Valid {
+		return nil
+	}
+	s := n.String
+	if !strings.ContainsAny(s, ".eE") {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
+		return s
+	}
+	if significantDigits(s) > maxExactDigits {
+		return s
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return s
+	}
+	if f == float64(int64(f)) && !strings.ContainsAny(s, "eE") {
+		return int64(f)
+	}
+	return f
+}
+
+func significantDigits(s string) int {
+	mantissa, _, _ := strings.Cut(strings.ToLower(s), "e")
+	digits := strings.TrimLeft(strings.NewReplacer("-", "", "+", "", ".", "").Replace(mantissa), "0")
+	if strings.Contains(mantissa, ".") {
+		digits = strings.TrimRight(digits, "0")
+	}
+	return len(digits)
+}
+
+// timeValue reads DATE and TIMESTAMP columns as time.Time, or as unix nano seconds if unix is set.
+type timeValue struct {
+	sql.NullTime
+	unix bool
+}
+
+func (t *timeValue) value() any {
+	if !t.Valid {
+		return nil
+	}
+	if t.unix {
+		return t.Time.UnixNano()
+	}
+	return t.Time
+}
+
+// bytesValue reads RAW and BLOB columns as base64 encoded text
+type bytesValue struct {
+	b     []byte
+	valid bool
+}
+
+func (b *bytesValue) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		b.b, b.valid = nil, false
+	case []byte:
+		b.b, b.valid = append(b.b[:0], v...), true
+	case string:
+		b.b, b.valid = []byte(v), true
+	default:
+		return fmt.Errorf("cannot scan %T into bytes", src)
+	}
+	return nil
+}
+
+func (b *bytesValue) value() any {
+	if !b.valid {
+		return nil
+	}
+	return base64.StdEncoding.EncodeToString(b.b)
+}
+
+// jsonValue reads native JSON columns as decoded json values
+type jsonValue struct {
+	v any
+}
+
+func (j *jsonValue) Scan(src any) error {
+	j.v = nil
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, &j.v)
+	case string:
+		return json.Unmarshal([]byte(v), &j.v)
+	default:
+		j.v = v
+	}
+	return nil
+}
+
+func (j *jsonValue) value() any {
+	return j.v
+}
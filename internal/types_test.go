@@ -0,0 +1,89 @@
+package layer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	go_ora "github.com/sijms/go-ora/v2"
+)
+
+func TestScanTargets(t *testing.T) {
+	t.Run("numbers should keep their precision", func(t *testing.T) {
+		for _, c := range []struct {
+			in  string
+			exp any
+		}{
+			{"32", int64(32)},
+			{"-7", int64(-7)},
+			{"5.2", 5.2},
+			{"5.0", int64(5)},
+			{"0.000123", 0.000123},
+			{"9223372036854775807", int64(9223372036854775807)},
+			{"12345678901234567890123456789012", "12345678901234567890123456789012"},
+			{"1234567890.1234567891", "1234567890.1234567891"},
+			{"1.5E+40", 1.5e40},
+		} {
+			n := &numberValue{}
+			if err := n.Scan(c.in); err != nil {
+				t.Fatal(err)
+			}
+			if v := n.value(); v != c.exp {
+				t.Fatalf("expected %s to be read as %#v, got %#v", c.in, c.exp, v)
+			}
+		}
+		n := &numberValue{}
+		n.Scan(nil)
+		if n.value() != nil {
+			t.Fatalf("expected nil, got %v", n.value())
+		}
+	})
+	t.Run("all date and timestamp types should be read as time", func(t *testing.T) {
+		for _, tt := range []go_ora.TNSType{go_ora.DATE, go_ora.TIMESTAMP, go_ora.TimeStampDTY, go_ora.TIMESTAMPTZ,
+			go_ora.TimeStampTZ_DTY, go_ora.TimeStampeLTZ, go_ora.TimeStampLTZ_DTY} {
+			// the driver reports the name of the TNS type as database type name
+			if _, ok := scanTargetOf(tt.String(), nil).(*timeValue); !ok {
+				t.Fatalf("expected time scan target for %s", tt)
+			}
+		}
+	})
+	t.Run("timestamps should be read as time or unix nanos", func(t *testing.T) {
+		ts := time.Date(2021, 1, 1, 12, 0, 0, 500, time.FixedZone("CET", 3600))
+		v := &timeValue{}
+		v.Scan(ts)
+		if v.value() != ts {
+			t.Fatalf("unexpected time %v", v.value())
+		}
+		v = &timeValue{unix: true}
+		v.Scan(ts)
+		if v.value() != ts.UnixNano() {
+			t.Fatalf("unexpected unix time %v", v.value())
+		}
+	})
+	t.Run("raw values should be base64 encoded", func(t *testing.T) {
+		b := &bytesValue{}
+		b.Scan([]byte("AAABBBCCCDDD"))
+		if b.value() != "QUFBQkJCQ0NDRERE" {
+			t.Fatalf("unexpected value %v", b.value())
+		}
+		b.Scan(nil)
+		if b.value() != nil {
+			t.Fatalf("expected nil, got %v", b.value())
+		}
+	})
+	t.Run("json values should be decoded", func(t *testing.T) {
+		j := &jsonValue{}
+		j.Scan([]byte(`{"a": [1, "b"]}`))
+		if !reflect.DeepEqual(j.value(), map[string]any{"a": []any{1.0, "b"}}) {
+			t.Fatalf("unexpected value %v", j.value())
+		}
+	})
+	t.Run("row item should return converted values", func(t *testing.T) {
+		n := &numberValue{}
+		n.Scan("42")
+		ri := &RowItem{Map: map[string]any{"N": n}}
+		if ri.GetValue("N") != int64(42) {
+			t.Fatalf("unexpected value %v", ri.GetValue("N"))
+		}
+	})
+}
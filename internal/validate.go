@@ -0,0 +1,239 @@
+package layer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	common "github.com/mimiro-io/common-datalayer"
+)
+
+// validateSystemConfig checks the connection settings in system_config, so that a broken
+// configuration is reported instead of failing when the connection pool is created
+func validateSystemConfig(c oraConf) error {
+	errs := validateConnection(c)
+	if v, ok := c.NativeSystemConfig[Connections]; ok {
+		if _, ok := v.(map[string]any); !ok {
+			errs = append(errs, fmt.Errorf("system_config key %s must be an object of named connections", Connections))
+		}
+	}
+	for _, name := range c.connectionNames() {
+		cc, err := c.connection(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w, it must be an object", err))
+			continue
+		}
+		for _, err := range validateConnection(cc) {
+			errs = append(errs, fmt.Errorf("connection %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateConnection checks the settings of one connection
+func validateConnection(c oraConf) []error {
+	var errs []error
+	for _, key := range []string{OracleHostname, OracleDB, OracleUser, OraclePassword} {
+		if s, ok := c.NativeSystemConfig[key].(string); !ok || s == "" {
+			errs = append(errs, fmt.Errorf("system_config key %s must be a non-empty string", key))
+		}
+	}
+	if _, err := c.int(OraclePort); err != nil {
+		errs = append(errs, err)
+	}
+	for _, key := range []string{MaxOpenConns, MaxIdleConns} {
+		if _, err := c.optInt(key, 0); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, key := range []string{ConnMaxLifetime, ConnMaxIdleTime} {
+		if _, err := c.optDuration(key, 0); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// validateDatasets checks all dataset definitions of a configuration against the system config c.
+// If pools are given, the tables, columns and queries of the datasets are checked against the database
+// of their connection as well. The default connection has the empty name.
+// All problems are reported together, prefixed with the dataset name.
+func validateDatasets(c oraConf, pools map[string]*sql.DB, definitions []*common.DatasetDefinition) error {
+	var errs []error
+	seen := map[string]bool{}
+	for _, def := range definitions {
+		if def.DatasetName == "" {
+			errs = append(errs, fmt.Errorf("dataset definition without name"))
+			continue
+		}
+		if seen[def.DatasetName] {
+			errs = append(errs, fmt.Errorf("dataset %s: defined more than once", def.DatasetName))
+			continue
+		}
+		seen[def.DatasetName] = true
+		dsErrs := validateDefinition(c, def)
+		if db := pools[connectionName(def)]; len(dsErrs) == 0 && db != nil {
+			dsErrs = validateSchema(db, def)
+		}
+		for _, err := range dsErrs {
+			errs = append(errs, fmt.Errorf("dataset %s: %w", def.DatasetName, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateDefinition checks the source config options and mappings of a dataset definition
+func validateDefinition(c oraConf, def *common.DatasetDefinition) []error {
+	var errs []error
+	sc := def.SourceConfig
+	if sc == nil {
+		return []error{fmt.Errorf("source_config is missing")}
+	}
+	table, tableOk := sc[TableName].(string)
+	query, queryOk := sc[Query].(string)
+	if _, ok := sc[TableName]; ok && !tableOk {
+		errs = append(errs, fmt.Errorf("%s must be a string", TableName))
+	}
+	if _, ok := sc[Query]; ok && !queryOk {
+		errs = append(errs, fmt.Errorf("%s must be a string", Query))
+	}
+	if table == "" && query == "" {
+		errs = append(errs, fmt.Errorf("%s or %s is required", TableName, Query))
+	}
+	if query != "" {
+		if sc[SnapshotSCN] == true {
+			errs = append(errs, fmt.Errorf("%s can not be used with a query", SnapshotSCN))
+		}
+		if ct, ok := sc[ChangeTracking].(string); ok && ct != "" {
+			errs = append(errs, fmt.Errorf("%s %s can not be used with a query", ChangeTracking, ct))
+		}
+	}
+	for _, key := range []string{AppendMode, SnapshotSCN, RequireRowDeps, ChangeLog, CreateTable, EvolveSchema, OnlyNewer} {
+		if v, ok := sc[key]; ok {
+			if _, ok := v.(bool); !ok {
+				errs = append(errs, fmt.Errorf("%s must be true or false", key))
+			}
+		}
+	}
+	if v, ok := sc[Connection]; ok {
+		if name, ok := v.(string); !ok {
+			errs = append(errs, fmt.Errorf("%s must be a string", Connection))
+		} else if _, err := c.connection(name); name != "" && err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, key := range []string{SinceColumn, RecordedColumn, FullSyncTable, CommitMode, DeleteMode, DeletedColumn, DeletedAtColumn,
+		ErrorPolicy, RejectTable, RejectFile} {
+		if v, ok := sc[key]; ok {
+			if _, ok := v.(string); !ok {
+				errs = append(errs, fmt.Errorf("%s must be a string", key))
+			}
+		}
+	}
+	if v, ok := sc[FlushThreshold]; ok {
+		if f, ok := v.(float64); !ok || f < 1 || f != float64(int(f)) {
+			errs = append(errs, fmt.Errorf("%s must be a positive integer", FlushThreshold))
+		}
+	}
+	if v, ok := sc[ChangeTracking]; ok {
+		if ct, _ := v.(string); ct != "" && ct != ChangeTrackingRowSCN && ct != ChangeTrackingLogMiner {
+			errs = append(errs, fmt.Errorf("%s must be %s or %s", ChangeTracking, ChangeTrackingRowSCN, ChangeTrackingLogMiner))
+		}
+	}
+	if cm, ok := sc[CommitMode].(string); ok && cm != CommitPerRequest && cm != CommitPerFlush && cm != CommitAutonomous {
+		errs = append(errs, fmt.Errorf("%s must be %s, %s or %s", CommitMode, CommitPerRequest, CommitPerFlush, CommitAutonomous))
+	}
+	if sc[OnlyNewer] == true {
+		if sc[AppendMode] == true {
+			errs = append(errs, fmt.Errorf("%s can not be used with %s", OnlyNewer, AppendMode))
+		} else if incomingRecordedColumn(def) == "" {
+			errs = append(errs, fmt.Errorf("%s requires a recorded property in the incoming mapping", OnlyNewer))
+		}
+	}
+	if _, err := deletionOf(def); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := rejectionOf(def); err != nil {
+		errs = append(errs, err)
+	}
+	keys, lerr := keyColumns(def)
+	if lerr != nil {
+		errs = append(errs, lerr.Underlying())
+	}
+
+	if def.OutgoingMappingConfig == nil {
+		errs = append(errs, fmt.Errorf("outgoing_mapping_config is missing"))
+	}
+	if def.IncomingMappingConfig != nil && sc[AppendMode] != true {
+		errs = append(errs, unmappedKeyColumns(def, keys)...)
+	}
+	return errs
+}
+
+// validateSchema checks that the table of a dataset exists and contains the columns used by the
+// dataset. custom queries are checked with validateQuery
+func validateSchema(db *sql.DB, def *common.DatasetDefinition) []error {
+	if q, ok := def.SourceConfig[Query].(string); ok && q != "" {
+		if err := validateQuery(db, def); err != nil {
+			return []error{fmt.Errorf("invalid query. %w", err)}
+		}
+		return nil
+	}
+	table := def.SourceConfig[TableName].(string)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	columns, err := tableColumns(ctx, db, table)
+	if err != nil {
+		return []error{err}
+	}
+	if len(columns) == 0 {
+		if def.SourceConfig[CreateTable] == true {
+			// the table is created by the first write
+			return nil
+		}
+		return []error{fmt.Errorf("table %s not found", table)}
+	}
+
+	var errs []error
+	exists := func(col string) bool {
+		_, ok := columns[strings.ToUpper(col)]
+		// pseudo columns are not listed in the data dictionary
+		return ok || strings.EqualFold(col, "ROWID") || strings.EqualFold(col, "ORA_ROWSCN")
+	}
+	if omc := def.OutgoingMappingConfig; omc != nil && !omc.MapAll {
+		for _, pm := range omc.PropertyMappings {
+			if !exists(pm.Property) {
+				errs = append(errs, fmt.Errorf("outgoing mapping column %s not found in table %s", pm.Property, table))
+			}
+		}
+	}
+	if imc := def.IncomingMappingConfig; imc != nil && def.SourceConfig[EvolveSchema] != true {
+		for _, pm := range imc.PropertyMappings {
+			if !exists(pm.Property) {
+				errs = append(errs, fmt.Errorf("incoming mapping column %s not found in table %s", pm.Property, table))
+			}
+		}
+	}
+	for _, key := range []string{SinceColumn, RecordedColumn, DeletedColumn, DeletedAtColumn} {
+		// evolve_schema adds missing soft delete columns with the first write
+		evolved := def.SourceConfig[EvolveSchema] == true && (key == DeletedColumn || key == DeletedAtColumn)
+		if col, _ := def.SourceConfig[key].(string); col != "" && !evolved && !exists(col) {
+			errs = append(errs, fmt.Errorf("%s %s not found in table %s", key, col, table))
+		}
+	}
+	if r, _ := rejectionOf(def); r.table != "" {
+		rejectCols, err := tableColumns(ctx, db, r.table)
+		if err != nil {
+			return append(errs, err)
+		}
+		for _, col := range rejectColumns {
+			if _, ok := rejectCols[col]; !ok {
+				errs = append(errs, fmt.Errorf("%s %s has no column %s", RejectTable, r.table, col))
+			}
+		}
+	}
+	return errs
+}
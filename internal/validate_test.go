@@ -0,0 +1,124 @@
+package layer
+
+import (
+	"strings"
+	"testing"
+
+	common "github.com/mimiro-io/common-datalayer"
+)
+
+func TestValidateConfig(t *testing.T) {
+	t.Run("should report all problems of all datasets", func(t *testing.T) {
+		bad := testDefinition(map[string]any{FlushThreshold: "100", AppendMode: "yes", ChangeTracking: "triggers", CommitMode: "sometimes"})
+		soft := testDefinition(map[string]any{DeleteMode: DeleteSoft})
+		soft.DatasetName = "soft"
+		reject := testDefinition(map[string]any{ErrorPolicy: ErrorPolicyReject})
+		reject.DatasetName = "reject"
+		noTable := &common.DatasetDefinition{DatasetName: "other", SourceConfig: map[string]any{}}
+		err := validateDatasets(oraConf{}, nil, []*common.DatasetDefinition{bad, noTable, soft, reject, testDefinition(nil)})
+		if err == nil {
+			t.Fatal("expected validation error")
+		}
+		for _, expected := range []string{
+			"dataset test: flush_threshold must be a positive integer",
+			"dataset test: append_mode must be true or false",
+			"dataset test: change_tracking must be ora_rowscn or logminer",
+			"dataset test: commit_mode must be per_request, per_flush or autonomous",
+			"dataset other: table_name or query is required",
+			"dataset other: outgoing_mapping_config is missing",
+			"dataset soft: delete_mode soft requires deleted_column or a deleted property in the incoming mapping",
+			"dataset reject: error_policy reject requires either reject_table or reject_file",
+			"dataset test: defined more than once",
+		} {
+			if !strings.Contains(err.Error(), expected) {
+				t.Fatalf("expected %q in %s", expected, err)
+			}
+		}
+	})
+	t.Run("should require incoming key columns in upsert mode", func(t *testing.T) {
+		def := testDefinition(map[string]any{KeyColumns: []any{"TENANT", "CODE"}})
+		def.IncomingMappingConfig = &common.IncomingMappingConfig{
+			PropertyMappings: []*common.EntityToItemPropertyMapping{{Property: "code", IsIdentity: true}},
+		}
+		err := validateDatasets(oraConf{}, nil, []*common.DatasetDefinition{def})
+		if err == nil || !strings.Contains(err.Error(), "key column TENANT is not mapped") {
+			t.Fatalf("expected key column error, got %v", err)
+		}
+		def.SourceConfig[AppendMode] = true
+		if err = validateDatasets(oraConf{}, nil, []*common.DatasetDefinition{def}); err != nil {
+			t.Fatalf("expected no error in append mode, got %v", err)
+		}
+	})
+	t.Run("should require incoming recorded column for only_newer", func(t *testing.T) {
+		def := testDefinition(map[string]any{OnlyNewer: true})
+		def.IncomingMappingConfig = &common.IncomingMappingConfig{
+			PropertyMappings: []*common.EntityToItemPropertyMapping{{Property: "id", IsIdentity: true}},
+		}
+		err := validateDatasets(oraConf{}, nil, []*common.DatasetDefinition{def})
+		if err == nil || !strings.Contains(err.Error(), "only_newer requires a recorded property in the incoming mapping") {
+			t.Fatalf("expected recorded column error, got %v", err)
+		}
+		def.IncomingMappingConfig.PropertyMappings = append(def.IncomingMappingConfig.PropertyMappings,
+			&common.EntityToItemPropertyMapping{Property: "recorded", IsRecorded: true})
+		if err = validateDatasets(oraConf{}, nil, []*common.DatasetDefinition{def}); err != nil {
+			t.Fatalf("expected valid definition, got %v", err)
+		}
+	})
+	t.Run("should keep previous configuration if update is invalid", func(t *testing.T) {
+		conf, _, _ := testDeps()
+		dl := &OracleDatalayer{datasets: map[string]*Dataset{}, config: conf}
+		if err := dl.UpdateConfiguration(conf); err != nil {
+			t.Fatal(err)
+		}
+		err := dl.UpdateConfiguration(&common.Config{DatasetDefinitions: []*common.DatasetDefinition{
+			testDefinition(map[string]any{TableName: 42}),
+		}})
+		if err == nil || !strings.Contains(err.Error(), "table_name must be a string") {
+			t.Fatalf("expected table_name error, got %v", err)
+		}
+		ds, _ := dl.Dataset("test")
+		if ds == nil || ds.MetaData()[TableName] != "test" {
+			t.Fatalf("expected previous definition to be kept, got %v", ds)
+		}
+	})
+	t.Run("should reject invalid system config", func(t *testing.T) {
+		conf, _, _ := testDeps()
+		conf.NativeSystemConfig[OraclePort] = "15x21"
+		delete(conf.NativeSystemConfig, OracleUser)
+		err := validateSystemConfig(oracleConf(conf))
+		if err == nil || !strings.Contains(err.Error(), "oracle_port is not an int") ||
+			!strings.Contains(err.Error(), "oracle_user must be a non-empty string") {
+			t.Fatalf("expected port and user errors, got %v", err)
+		}
+		conf.NativeSystemConfig[OraclePort] = float64(1521)
+		conf.NativeSystemConfig[OracleUser] = "test"
+		if err = validateSystemConfig(oracleConf(conf)); err != nil {
+			t.Fatalf("expected valid config, got %v", err)
+		}
+	})
+	t.Run("should validate named connections", func(t *testing.T) {
+		conf, _, _ := testDeps()
+		conf.NativeSystemConfig[Connections] = map[string]any{
+			"hr":  map[string]any{OracleUser: "hr", OraclePassword: "secret"},
+			"bad": map[string]any{OraclePort: "x"},
+		}
+		c := oracleConf(conf)
+		err := validateSystemConfig(c)
+		if err == nil || !strings.Contains(err.Error(), "connection bad: system_config key oracle_port is not an int") {
+			t.Fatalf("expected port error of connection bad, got %v", err)
+		}
+		hr, err := c.connection("hr")
+		if err != nil || hr.str(OracleUser) != "hr" || hr.str(OracleHostname) != "test" || hr.NativeSystemConfig[Connections] != nil {
+			t.Fatalf("expected hr settings merged with system_config, got %v, %v", hr, err)
+		}
+
+		err = validateDatasets(c, nil, []*common.DatasetDefinition{
+			testDefinition(map[string]any{Connection: "hr"}),
+			{DatasetName: "other", SourceConfig: map[string]any{TableName: "other", Connection: "sales"},
+				OutgoingMappingConfig: &common.OutgoingMappingConfig{MapAll: true}},
+		})
+		if err == nil || err.Error() != "dataset other: connection sales is not defined in system_config connections" {
+			t.Fatalf("expected undefined connection error, got %v", err)
+		}
+	})
+}
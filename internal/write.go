@@ -4,26 +4,84 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
 	common "github.com/mimiro-io/common-datalayer"
 	egdm "github.com/mimiro-io/entity-graph-data-model"
+	go_ora "github.com/sijms/go-ora/v2"
 )
 
+// FullSync writes all batches of a sync into a staging table. When the last batch is closed,
+// the staging table replaces the content of the target table in one transaction, so that rows
+// which were not part of the sync are removed.
+// Only one full sync per dataset can be active. A new start batch abandons and cleans up an
+// active sync with a different sync id, other batches with an unknown sync id are rejected.
 func (d *Dataset) FullSync(ctx context.Context, batchInfo common.BatchInfo) (common.DatasetWriter, common.LayerError) {
-	// TODO not supported (yet?)
-	return nil, ErrNotSupported
+	writer, err := d.newOracleWriter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	staging := stagingTable(d.datasetDefinition)
+
+	d.fullSyncLock.Lock()
+	defer d.fullSyncLock.Unlock()
+	if batchInfo.IsStartBatch {
+		if d.fullSyncID != "" && d.fullSyncID != batchInfo.SyncId {
+			d.logger.Warn(fmt.Sprintf("abandoning full sync %s for dataset %s, new sync %s started",
+				d.fullSyncID, d.Name(), batchInfo.SyncId), "dataset", d.Name())
+		}
+		err := prepareStaging(ctx, writer.db, writer.table, staging, !writer.appendMode)
+		if err != nil {
+			return nil, ErrQuery(err)
+		}
+		d.fullSyncID = batchInfo.SyncId
+	} else if d.fullSyncID != batchInfo.SyncId {
+		return nil, ErrFullSyncNotActive(batchInfo.SyncId, d.Name())
+	}
+
+	writer.fullSync = &fullSync{
+		dataset:    d,
+		syncID:     batchInfo.SyncId,
+		target:     writer.table,
+		targetMode: writer.appendMode,
+		deletion:   writer.deletion,
+		lastBatch:  batchInfo.IsLastBatch,
+	}
+	// all batches are appended to the staging table. the target table is updated when the sync completes.
+	// a batch is only added to the staging table as a whole, independent of the commit mode
+	writer.table = staging
+	writer.appendMode = true
+	writer.commitMode = CommitPerRequest
+	err2 := writer.begin()
+	if err2 != nil {
+		return nil, ErrConnection(err2)
+	}
+	return writer, nil
 }
 
+// Incremental writes entities to the dataset table. commit_mode in source config decides when
+// changes are committed: at the end of the request (per_request, default), after every batch
+// (per_flush), or by every statement without transaction (autonomous).
 func (d *Dataset) Incremental(ctx context.Context) (common.DatasetWriter, common.LayerError) {
 	writer, err := d.newOracleWriter(ctx)
-	writer.begin()
-	return writer, err
+	if err != nil {
+		return nil, err
+	}
+	if err := writer.begin(); err != nil {
+		return nil, ErrConnection(err)
+	}
+	return writer, nil
 }
 
 func (d *Dataset) newOracleWriter(ctx context.Context) (*OracleWriter, common.LayerError) {
+	if d.datasetDefinition.IncomingMappingConfig == nil {
+		return nil, ErrGeneric("incoming mapping config is missing in dataset %s", d.datasetDefinition.DatasetName)
+	}
 	mapper := common.NewMapper(d.logger, d.datasetDefinition.IncomingMappingConfig, d.datasetDefinition.OutgoingMappingConfig)
-	db := sql.OpenDB(d.db.connector)
+	db := d.db.pool
 	tableName, ok := d.datasetDefinition.SourceConfig[TableName].(string)
 	if !ok {
 		return nil, ErrGeneric("table name not found in source config for dataset %s", d.datasetDefinition.DatasetName)
@@ -37,230 +95,719 @@ func (d *Dataset) newOracleWriter(ctx context.Context) (*OracleWriter, common.La
 		}
 		flushThreshold = int(flushThresholdF)
 	}
-	idColumn := "id"
-	for _, m := range d.datasetDefinition.IncomingMappingConfig.PropertyMappings {
-		if m.IsIdentity {
-			idColumn = m.Property
-			break
+	keyCols, err := keyColumns(d.datasetDefinition)
+	if err != nil {
+		return nil, err
+	}
+	appendMode := d.datasetDefinition.SourceConfig[AppendMode] == true
+	del, derr := deletionOf(d.datasetDefinition)
+	if derr != nil {
+		return nil, ErrGeneric("%s in dataset %s", derr.Error(), d.datasetDefinition.DatasetName)
+	}
+	rej, rerr := rejectionOf(d.datasetDefinition)
+	if rerr != nil {
+		return nil, ErrGeneric("%s in dataset %s", rerr.Error(), d.datasetDefinition.DatasetName)
+	}
+	var recordedCol string
+	if d.datasetDefinition.SourceConfig[OnlyNewer] == true && !appendMode {
+		recordedCol = incomingRecordedColumn(d.datasetDefinition)
+		if recordedCol == "" {
+			return nil, ErrGeneric("%s requires a recorded property in the incoming mapping of dataset %s", OnlyNewer, d.datasetDefinition.DatasetName)
+		}
+	}
+	commitMode, _ := d.datasetDefinition.SourceConfig[CommitMode].(string)
+	if commitMode == "" {
+		commitMode = CommitPerRequest
+	}
+	if !appendMode {
+		if errs := unmappedKeyColumns(d.datasetDefinition, keyCols); len(errs) > 0 {
+			return nil, ErrGeneric("%s in dataset %s", errs[0].Error(), d.datasetDefinition.DatasetName)
+		}
+	}
+	// the column types of the target table decide how entity values are converted before binding.
+	// the staging table of a full sync has the same columns as the target table
+	columns, lerr := tableColumns(ctx, db, tableName)
+	if lerr != nil {
+		return nil, ErrQuery(lerr)
+	}
+	columns, lerr = d.ensureSchema(ctx, db, tableName, columns, keyCols, appendMode, del)
+	if lerr != nil {
+		return nil, ErrSchema(d.Name(), lerr)
+	}
+	if del.mode == DeleteSoft {
+		col, ok := columns[strings.ToUpper(del.column)]
+		if !ok {
+			return nil, ErrGeneric("%s %s not found in table %s", DeletedColumn, del.column, tableName)
+		}
+		del.flag = flagLiteral(col)
+		if del.atColumn != "" {
+			if _, ok := columns[strings.ToUpper(del.atColumn)]; !ok {
+				return nil, ErrGeneric("%s %s not found in table %s", DeletedAtColumn, del.atColumn, tableName)
+			}
 		}
 	}
 	return &OracleWriter{
 		logger:         d.logger,
+		metrics:        d.db.metrics,
+		dataset:        d.Name(),
 		mapper:         mapper,
 		db:             db,
 		ctx:            ctx,
 		table:          tableName,
 		flushThreshold: flushThreshold,
-		appendMode:     d.datasetDefinition.SourceConfig[AppendMode] == true,
-		idColumn:       idColumn,
+		appendMode:     appendMode,
+		commitMode:     commitMode,
+		deletion:       del,
+		rejection:      rej,
+		recordedColumn: recordedCol,
+		keyColumns:     keyCols,
+		columns:        columns,
 	}, nil
 }
 
 type OracleWriter struct {
 	logger         common.Logger
+	metrics        common.Metrics
+	dataset        string
 	ctx            context.Context
 	mapper         *common.Mapper
 	db             *sql.DB
 	tx             *sql.Tx
 	table          string
-	idColumn       string
-	batch          strings.Builder
-	lastCols       []string
-	batchSize      int
+	keyColumns     []string
+	columns        map[string]tableColumn
+	batch          []*RowItem
 	flushThreshold int
 	appendMode     bool
+	commitMode     string
+	recordedColumn string // set if only newer entities are applied
+	deletion       deletion
+	rejection      rejection
+	fullSync       *fullSync
+	received       int // entities passed to Write
+	rejected       int // entities written to the reject destination
+	// err is the failure that stopped the writer. the transaction is rolled back when the writer
+	// fails, because the caller does not close a writer after a failed write
+	err common.LayerError
 }
 
 func (o *OracleWriter) Write(entity *egdm.Entity) common.LayerError {
-	item := &RowItem{Map: map[string]any{}}
+	if o.err != nil {
+		return ErrWriterFailed(o.err)
+	}
+	o.received++
+	item := &RowItem{Map: map[string]any{}, entity: entity}
 	err := o.mapper.MapEntityToItem(entity, item)
 	if err != nil {
-		return common.Err(err, common.LayerErrorInternal)
+		if o.rejection.enabled() {
+			return o.rejectOrFail(entity, rejectMapping, err)
+		}
+		return o.fail(common.Err(err, common.LayerErrorInternal))
 	}
-	// set the deleted flag, we always need this to do the right thing in upsert mode
-	item.deleted = entity.IsDeleted
-
-	if !o.appendMode {
-		// if dataset is in latest only mode, we only keep one row per entity (unique by id).
-		err = o.upsert(item)
-	} else {
-		// if dataset is not in latest only mode, we write all changes to the table. it is important to
-		// have the deleted flag mapped to a column in the table, so that the data can reflect the state correctly
-		err = o.append(item)
+	switch {
+	case entity.IsDeleted && o.deletion.mode == DeleteIgnore:
+		return nil
+	case o.deletion.mode == DeleteSoft:
+		// the deletion is written to the flag column, the row itself is kept
+		setColumn(item, o.deletion.column, entity.IsDeleted)
+		if o.deletion.atColumn != "" {
+			var at any
+			if entity.IsDeleted {
+				at = time.Now()
+			}
+			setColumn(item, o.deletion.atColumn, at)
+		}
 	}
-	if err != nil {
-		return common.Err(err, common.LayerErrorInternal)
+	for _, col := range item.Columns {
+		if tc, ok := o.columns[strings.ToUpper(col)]; ok {
+			v, err := convertValue(tc, item.Map[col])
+			if err != nil {
+				if o.rejection.enabled() {
+					return o.rejectOrFail(entity, rejectConversion, ErrConversion(entity.ID, col, err))
+				}
+				return o.fail(ErrConversion(entity.ID, col, err))
+			}
+			item.Map[col] = v
+		}
 	}
-	if o.batchSize >= o.flushThreshold {
+	// set the deleted flag, we always need this to do the right thing in upsert mode.
+	// soft deleted rows are updated like any other row
+	item.deleted = entity.IsDeleted && o.deletion.mode == DeleteHard
+	item.recorded = entity.Recorded
+	if o.fullSync != nil && !o.fullSync.targetMode {
+		// deleted entities are not part of the synced snapshot in upsert mode. they are written to
+		// the staging table with a flag, so that they replace earlier versions of the same key, and
+		// removed from staging when the sync completes
+		setColumn(item, stagingDeleted, item.deleted)
+	}
+
+	o.batch = append(o.batch, item)
+	if len(o.batch) >= o.flushThreshold {
 		err = o.flush()
 		if err != nil {
-			return common.Err(err, common.LayerErrorInternal)
+			return o.fail(common.Err(err, common.LayerErrorInternal))
 		}
-		o.batchSize = 0
-		o.batch.Reset()
+		o.batch = o.batch[:0]
 	}
 	return nil
 }
 
 func (o *OracleWriter) Close() common.LayerError {
+	if o.err != nil {
+		return ErrWriterFailed(o.err)
+	}
 	err := o.flush()
 	if err != nil {
-		return common.Err(err, common.LayerErrorInternal)
+		return o.fail(common.Err(err, common.LayerErrorInternal))
 	}
 	if o.tx != nil {
 		err = o.tx.Commit()
+		o.tx = nil
 		if err != nil {
-			return common.Err(err, common.LayerErrorInternal)
+			return o.fail(common.Err(err, common.LayerErrorInternal))
 		}
 		o.logger.Debug("Transaction committed")
 	}
-	err = o.db.Close()
-	if err != nil {
-		return common.Err(err, common.LayerErrorInternal)
+	if o.rejection.enabled() {
+		o.reportRejects()
+	}
+	if o.fullSync != nil && o.fullSync.lastBatch {
+		err = o.fullSync.complete(o.ctx, o.db, o.table, o.keyColumns)
+		if err != nil {
+			return common.Err(err, common.LayerErrorInternal)
+		}
 	}
 	return nil
 }
 
-// append builds an insert statement the complete batch.
-// the insert statement is completed and executed when the batch is flushed
-// en DEBUG mode, the complete statements are logged in flush
-func (o *OracleWriter) append(item *RowItem) error {
-	if o.batch.Len() == 0 {
-		o.batch.WriteString("INSERT ALL\n")
+// appendStatement builds an INSERT statement for the batch.
+// The statement text is the same for every batch, only containing column names and positional
+// placeholders. Values are passed as array binds, one array per column, so that the driver
+// executes the statement for all rows in a single round trip.
+func appendStatement(table string, batch []*RowItem) (string, []any) {
+	cols := batchColumns(batch)
+	var sb strings.Builder
+	sb.WriteString("INSERT INTO ")
+	sb.WriteString(quoteTable(table))
+	sb.WriteString(" (")
+	for i, k := range cols {
+		if i != 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(quoteIdent(k))
+	}
+	sb.WriteString(") VALUES (")
+	for i := range cols {
+		if i != 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(placeholder(i + 1))
+	}
+	sb.WriteString(")")
+	return sb.String(), bindColumns(cols, batch, false)
+}
+
+// Oracle does not have a proper upsert, but we can achieve the same (and even deletes) with a merge statement
+// MERGE is supported in Oracle 9i and later
+// mergeStatement selects one row of bind variables from dual and merges it into the target table.
+// with array binds, the merge is executed once per row in the batch.
+// The synthetic _DELETED column carries the entity deleted flag, so that the merge command can delete rows.
+// If recordedCol is set, matched rows are only updated or deleted if the incoming recorded value is newer.
+// If all columns are key columns, there is nothing to update and the update branch is left out.
+// rows of such tables are deleted with keyDeleteStatement.
+func mergeStatement(table string, keyCols []string, recordedCol string, batch []*RowItem) (string, []any) {
+	cols := batchColumns(batch)
+	// oracle does not allow updates of columns referenced in the ON clause
+	updates := updateColumns(cols, keyCols)
+	var sb strings.Builder
+	sb.WriteString("MERGE INTO ")
+	sb.WriteString(quoteTable(table))
+	sb.WriteString(" t USING (SELECT ")
+	for i, k := range cols {
+		sb.WriteString(placeholder(i + 1))
+		sb.WriteString(" AS ")
+		sb.WriteString(quoteIdent(k))
+		sb.WriteString(", ")
+	}
+	// The boolean type was introduced in oracle 23, we need to have backwards compatibility
+	sb.WriteString(placeholder(len(cols) + 1))
+	sb.WriteString(" AS \"_DELETED\" FROM dual) n ON (")
+	sb.WriteString(keyCondition(keyCols))
+	sb.WriteString(")")
+	if len(updates) > 0 {
+		sb.WriteString("\nWHEN MATCHED THEN UPDATE SET ")
+		for i, col := range updates {
+			if i != 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString("t." + quoteIdent(col) + " = n." + quoteIdent(col))
+		}
+		if recordedCol != "" {
+			// the delete branch only sees rows that passed this condition, with the updated values.
+			// so the condition guards deletes as well, it can not be repeated in the delete branch.
+			// incoming rows without recorded value are always applied
+			rec := quoteIdent(recordedCol)
+			sb.WriteString("\nWHERE n." + rec + " IS NULL OR t." + rec + " IS NULL OR n." + rec + " > t." + rec)
+		}
+		sb.WriteString("\nDELETE WHERE n.\"_DELETED\" = 'true'")
 	}
-	o.batch.WriteString("\tINTO \"")
-	o.batch.WriteString(strings.ToUpper(o.table))
-	o.batch.WriteString("\" (")
-	for i, k := range item.Columns {
+	sb.WriteString("\nWHEN NOT MATCHED THEN INSERT (")
+	for i, col := range cols {
 		if i != 0 {
-			o.batch.WriteString(", ")
+			sb.WriteString(", ")
 		}
-		o.batch.WriteRune('"')
-		o.batch.WriteString(strings.ToUpper(k))
-		o.batch.WriteRune('"')
+		sb.WriteString(quoteIdent(col))
 	}
-	o.batch.WriteString(") VALUES (")
-	for i, v := range item.Values {
+	sb.WriteString(") VALUES (")
+	for i, col := range cols {
 		if i != 0 {
-			o.batch.WriteString(", ")
+			sb.WriteString(", ")
 		}
-		o.batch.WriteString(sqlVal(v))
+		sb.WriteString("n." + quoteIdent(col))
 	}
-	o.batch.WriteString(")\n")
-	o.batchSize++
-	return nil
+	// deleted entities without stored row are not inserted
+	sb.WriteString(") WHERE n.\"_DELETED\" <> 'true'")
+	return sb.String(), bindColumns(cols, batch, true)
 }
 
-func sqlVal(v any) string {
-	switch v.(type) {
-	case string:
-		return fmt.Sprintf("'%s'", v)
-	case nil:
-		return "NULL"
-	case bool:
-		return fmt.Sprintf("'%t'", v)
-	default:
-		return fmt.Sprintf("%v", v)
+// updateColumns returns the columns that are not key columns
+func updateColumns(cols, keyCols []string) []string {
+	var updates []string
+	for _, col := range cols {
+		if !containsColumn(keyCols, col) {
+			updates = append(updates, col)
+		}
 	}
+	return updates
 }
 
-func (o *OracleWriter) flush() error {
-	if o.batchSize == 0 {
-		return nil
+// keyDeleteStatement deletes the rows of deleted entities by key, for tables that only have key
+// columns. the merge statement of such tables can not delete rows, it has no update branch.
+// all rows of the batch are bound, rows of entities that are not deleted do not match
+func keyDeleteStatement(table string, keyCols []string, batch []*RowItem) (string, []any) {
+	var keys []string
+	for _, col := range batchColumns(batch) {
+		if containsColumn(keyCols, col) {
+			keys = append(keys, col)
+		}
 	}
-	if o.appendMode {
-		o.batch.WriteString("SELECT 1 FROM dual")
-	} else {
-		o.batch.WriteString(")) n ON (t.\"ID\" = n.\"ID\")\nWHEN MATCHED THEN UPDATE SET ")
-		needComma := false
-		for _, col := range o.lastCols {
-			if col == o.idColumn {
+	var sb strings.Builder
+	sb.WriteString("DELETE FROM ")
+	sb.WriteString(quoteTable(table))
+	sb.WriteString(" t WHERE ")
+	for i, k := range keys {
+		sb.WriteString("t." + quoteIdent(k) + " = " + placeholder(i+1) + " AND ")
+	}
+	sb.WriteString(placeholder(len(keys)+1) + " = 'true'")
+	return sb.String(), bindColumns(keys, batch, true)
+}
+
+// countDeleted returns the number of deleted rows in the batch
+func countDeleted(rows []*RowItem) int {
+	n := 0
+	for _, item := range rows {
+		if item.deleted {
+			n++
+		}
+	}
+	return n
+}
+
+// keyCondition joins target (t) and source (n) rows on all key columns
+func keyCondition(keyCols []string) string {
+	conds := make([]string, len(keyCols))
+	for i, k := range keyCols {
+		conds[i] = "t." + quoteIdent(k) + " = n." + quoteIdent(k)
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// containsColumn checks if col is in cols, ignoring case like oracle does for unquoted identifiers
+func containsColumn(cols []string, col string) bool {
+	for _, k := range cols {
+		if strings.EqualFold(col, k) {
+			return true
+		}
+	}
+	return false
+}
+
+func placeholder(pos int) string {
+	return ":" + strconv.Itoa(pos)
+}
+
+// batchColumns lists the columns of all rows in the batch, in the order they first appear.
+// the mapper only sets columns for references the entity has, so rows of a batch can have
+// different columns. rows without a value for a column are bound as NULL
+func batchColumns(batch []*RowItem) []string {
+	cols := batch[0].Columns
+	seen := make(map[string]bool, len(cols))
+	for _, col := range cols {
+		seen[col] = true
+	}
+	for _, item := range batch[1:] {
+		for _, col := range item.Columns {
+			if !seen[col] {
+				seen[col] = true
+				// copy on first extension, so that the columns of the first row are not modified
+				cols = append(cols[:len(cols):len(cols)], col)
+			}
+		}
+	}
+	return cols
+}
+
+// bindColumns transposes the batch into one value array per column, which is what go-ora
+// expects for array binds. If withDeleted is set, an additional array with the deleted flags is added.
+// go-ora uses one bind type per array, so columns with mixed value types are bound as strings.
+func bindColumns(cols []string, batch []*RowItem, withDeleted bool) []any {
+	args := make([]any, 0, len(cols)+1)
+	for _, col := range cols {
+		vals := make([]any, len(batch))
+		var kind reflect.Kind
+		mixed := false
+		for r, item := range batch {
+			v := bindVal(item.Map[col])
+			vals[r] = v
+			if v == nil {
 				continue
 			}
-			if needComma {
-				o.batch.WriteString(", ")
+			k := bindKind(v)
+			if kind != reflect.Invalid && kind != k {
+				mixed = true
 			}
-			o.batch.WriteString(fmt.Sprintf("t.\"%s\" = n.\"%s\"", strings.ToUpper(col), strings.ToUpper(col)))
-			needComma = true
+			kind = k
 		}
-		o.batch.WriteString("\nDELETE WHERE n.\"_DELETED\" = 'true'")
-		o.batch.WriteString("\nWHEN NOT MATCHED THEN INSERT (")
-		for i, col := range o.lastCols {
-			if i != 0 {
-				o.batch.WriteString(", ")
+		if mixed {
+			for r, v := range vals {
+				if v != nil {
+					vals[r] = fmt.Sprintf("%v", v)
+				}
 			}
-			o.batch.WriteRune('"')
-			o.batch.WriteString(strings.ToUpper(col))
-			o.batch.WriteRune('"')
 		}
-		o.batch.WriteString(") VALUES (")
-		for i, col := range o.lastCols {
-			if i != 0 {
-				o.batch.WriteString(", ")
+		args = append(args, vals)
+	}
+	if withDeleted {
+		vals := make([]any, len(batch))
+		for r, item := range batch {
+			vals[r] = bindVal(item.deleted)
+		}
+		args = append(args, vals)
+	}
+	return args
+}
+
+// bindKind groups bind values by the oracle type the driver will bind them as
+func bindKind(v any) reflect.Kind {
+	switch v.(type) {
+	case int, int32, int64, uint64, float32, float64:
+		return reflect.Float64
+	default:
+		return reflect.TypeOf(v).Kind()
+	}
+}
+
+// quoteIdent turns a mapped property name into a quoted, uppercase oracle identifier.
+func quoteIdent(name string) string {
+	return "\"" + strings.ReplaceAll(strings.ToUpper(name), "\"", "\"\"") + "\""
+}
+
+// quoteTable quotes an optionally schema qualified table name, each part on its own
+func quoteTable(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = quoteIdent(p)
+	}
+	return strings.Join(parts, ".")
+}
+
+// bindVal converts mapped entity values into values the driver can bind.
+// values converted to the column type by convertValue are passed through.
+// booleans are bound as 'true'/'false' strings for backwards compatibility with
+// pre-23 databases, and complex values fall back to their string representation.
+func bindVal(v any) any {
+	switch val := v.(type) {
+	case nil, string, int, int32, int64, uint64, float32, float64, time.Time, []byte,
+		go_ora.Number, go_ora.Clob, go_ora.NClob, go_ora.Blob:
+		return val
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+func (o *OracleWriter) flush() error {
+	if len(o.batch) == 0 {
+		return nil
+	}
+	if !o.appendMode || (o.fullSync != nil && !o.fullSync.targetMode) {
+		// the target table keeps one row per key, so only the last version of each key is written
+		var collapsed int
+		o.batch, collapsed = collapseBatch(o.batch, o.keyColumns)
+		if collapsed > 0 {
+			o.logger.Debug("collapsed duplicate keys in batch", "dataset", o.dataset, "duplicates", collapsed)
+			if err := o.count("oracle.write.batch_duplicates", collapsed); err != nil {
+				o.logger.Warn("failed to report batch duplicates", "error", err)
 			}
-			o.batch.WriteString(fmt.Sprintf("n.\"%s\"", strings.ToUpper(col)))
 		}
-		o.batch.WriteString(")")
 	}
-	stmt := o.batch.String()
-	o.logger.Debug(stmt)
-	res, err := o.tx.ExecContext(o.ctx, stmt)
+	var err error
+	if o.rejection.enabled() {
+		err = o.writeIsolated()
+	} else {
+		err = o.writeBatch(o.batch)
+	}
 	if err != nil {
-		if o.tx != nil {
-			err2 := o.tx.Rollback()
-			if err2 != nil {
-				o.logger.Error("Failed to rollback transaction")
-				return fmt.Errorf("failed to rollback transaction: %w, underlying: %w", err2, err)
-			}
-			o.logger.Debug("Transaction rolled back")
+		return err
+	}
+	if o.commitMode == CommitPerFlush {
+		err = o.tx.Commit()
+		o.tx = nil
+		if err != nil {
+			return err
 		}
+		o.logger.Debug("Transaction committed", "rows", len(o.batch))
+		return o.begin()
+	}
+	return nil
+}
+
+// writeBatch writes rows with one statement and checks that all rows are applied
+func (o *OracleWriter) writeBatch(rows []*RowItem) error {
+	var stmt string
+	var args []any
+	if o.appendMode {
+		// if dataset is in append mode, we write all changes to the table. it is important to
+		// have the deleted flag mapped to a column in the table, so that the data can reflect the state correctly
+		stmt, args = appendStatement(o.table, rows)
+	} else {
+		// if dataset is in latest only mode, we only keep one row per entity (unique by id).
+		stmt, args = mergeStatement(o.table, o.keyColumns, o.recordedColumn, rows)
+	}
+	// en DEBUG mode, the statements are logged. values are not logged, they are bound separately
+	o.logger.Debug(stmt, "rows", len(rows))
+	res, err := o.exec(o.ctx, stmt, args...)
+	if err != nil {
 		return err
 	}
 	seen, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
-	if int(seen) != o.batchSize {
-		return ErrBatchSizeMismatch(int(seen), o.batchSize)
+	keyOnly := !o.appendMode && len(updateColumns(batchColumns(rows), o.keyColumns)) == 0
+	deletes := 0
+	if !o.appendMode {
+		deletes = countDeleted(rows)
+	}
+	if keyOnly && deletes > 0 {
+		stmt, args = keyDeleteStatement(o.table, o.keyColumns, rows)
+		o.logger.Debug(stmt, "rows", len(rows))
+		res, err = o.exec(o.ctx, stmt, args...)
+		if err != nil {
+			return err
+		}
+		deleted, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		seen += deleted
+	}
+	switch {
+	case int(seen) == len(rows):
+	case int(seen) < len(rows) && o.recordedColumn != "" && !o.appendMode:
+		// rows with an older recorded value than the stored row are skipped by the merge
+		skipped := len(rows) - int(seen)
+		o.logger.Debug("skipped rows older than stored rows", "dataset", o.dataset, "rows", skipped)
+	case int(seen) < len(rows) && len(rows)-int(seen) <= deletes:
+		// deleted entities without stored row are neither inserted nor deleted by the merge
+	case keyOnly:
+		// rows of a table with only key columns that exist already are not changed by the merge,
+		// so the number of affected rows does not tell if all rows are applied
+	default:
+		return ErrBatchSizeMismatch(int(seen), len(rows))
 	}
 	return nil
 }
 
-// Oracle does not have a proper upsert, but we can achieve the same (and even deletes) with a merge statement
-// MERGE is supported in Oracle 9i and later
-// the first call to upsert will start a new MERGE, and subsequent calls will more UNION SELECTs to the batch
-// when the batch is flushed, the MERGE statement will be completed and  executed
-// en DEBUG mode, the complete statements are logged in flush
-func (o *OracleWriter) upsert(item *RowItem) error {
-	if o.batch.Len() == 0 {
-		o.batch.WriteString("MERGE INTO ")
-		o.batch.WriteString(strings.ToUpper(o.table))
-		o.batch.WriteString(" t USING ((\n\t")
+// writeIsolated writes the batch, and retries it row by row if it fails, so that only the failing
+// rows are rejected and the rest of the batch is kept. rows written by a failed array bound statement
+// are rolled back to a savepoint first. in autonomous commit mode, the batch gets its own transaction
+// for that. lost connections are not the fault of a row, they fail the writer.
+func (o *OracleWriter) writeIsolated() (err error) {
+	if o.tx == nil {
+		if o.tx, err = o.db.BeginTx(o.ctx, nil); err != nil {
+			return err
+		}
+		defer func() {
+			if err == nil {
+				err = o.tx.Commit()
+			} else if rerr := o.tx.Rollback(); rerr != nil {
+				o.logger.Error("Failed to rollback transaction", "error", rerr)
+			}
+			o.tx = nil
+		}()
+	}
+	if _, err = o.tx.ExecContext(o.ctx, "SAVEPOINT write_batch"); err != nil {
+		return err
+	}
+	err = o.writeBatch(o.batch)
+	if err == nil || isConnectionLost(err) {
+		return err
+	}
+	o.logger.Warn("batch failed, retrying row by row", "dataset", o.dataset, "rows", len(o.batch), "error", err)
+	if _, err = o.tx.ExecContext(o.ctx, "ROLLBACK TO SAVEPOINT write_batch"); err != nil {
+		return err
+	}
+	for _, item := range o.batch {
+		// a failed statement is rolled back by oracle, earlier rows of the transaction are kept
+		err = o.writeBatch([]*RowItem{item})
+		if err == nil {
+			continue
+		}
+		if isConnectionLost(err) {
+			return err
+		}
+		if err = o.reject(item.entity, rejectDatabase, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exec executes a statement in the transaction of the writer, or by itself in autonomous commit mode
+func (o *OracleWriter) exec(ctx context.Context, stmt string, args ...any) (sql.Result, error) {
+	if o.tx != nil {
+		return o.tx.ExecContext(ctx, stmt, args...)
+	}
+	return o.db.ExecContext(ctx, stmt, args...)
+}
+
+// rejectOrFail rejects an entity that can not be mapped or converted. if the reject can not be
+// written, the writer fails
+func (o *OracleWriter) rejectOrFail(entity *egdm.Entity, code string, err error) common.LayerError {
+	if rerr := o.reject(entity, code, err); rerr != nil {
+		return o.fail(rerr)
+	}
+	return nil
+}
+
+// reportRejects logs and reports the number of accepted and rejected entities of a request
+func (o *OracleWriter) reportRejects() {
+	accepted := o.received - o.rejected
+	if o.rejected > 0 {
+		o.logger.Warn("write completed with rejected entities", "dataset", o.dataset, "accepted", accepted, "rejected", o.rejected)
 	} else {
-		o.batch.WriteString("\n\tUNION ")
-	}
-	o.batch.WriteString("SELECT ")
-	for i, k := range item.Columns {
-		o.batch.WriteString(sqlVal(item.Values[i]))
-		o.batch.WriteString(" AS ")
-		o.batch.WriteRune('"')
-		o.batch.WriteString(strings.ToUpper(k))
-		o.batch.WriteRune('"')
-		// if i != len(item.Columns)-1 {
-		o.batch.WriteString(", ")
-		//}
-	}
-	// append synthetic column for deleted flag, so that the merge command can delete rows
-	// The boolean was introduced in oracle 23, we need to have backwards compatability
-	o.batch.WriteString(sqlVal(item.deleted))
-	o.batch.WriteString(" AS \"_DELETED\"")
-	o.batch.WriteString(" FROM dual")
-	o.batchSize++
-	o.lastCols = item.Columns
+		o.logger.Info("write completed", "dataset", o.dataset, "accepted", accepted, "rejected", 0)
+	}
+	for name, value := range map[string]int{"oracle.write.accepted": accepted, "oracle.write.rejected": o.rejected} {
+		if err := o.count(name, value); err != nil {
+			o.logger.Warn("failed to report write counts", "error", err)
+			return
+		}
+	}
+}
+
+// fail stops the writer and rolls back the uncommitted changes. batches that are already
+// committed (commit modes per_flush and autonomous) are kept
+func (o *OracleWriter) fail(err common.LayerError) common.LayerError {
+	o.err = err
+	o.batch = nil
+	if o.tx != nil {
+		if rerr := o.tx.Rollback(); rerr != nil {
+			o.logger.Error("Failed to rollback transaction", "error", rerr)
+			o.err = common.Err(fmt.Errorf("failed to rollback transaction: %w, underlying: %w", rerr, err), common.LayerErrorInternal)
+		} else {
+			o.logger.Debug("Transaction rolled back")
+		}
+		o.tx = nil
+	}
+	return o.err
+}
+
+// incomingRecordedColumn returns the column the incoming mapping writes the recorded timestamp to, if any
+func incomingRecordedColumn(definition *common.DatasetDefinition) string {
+	if definition.IncomingMappingConfig != nil {
+		for _, pm := range definition.IncomingMappingConfig.PropertyMappings {
+			if pm.IsRecorded {
+				return pm.Property
+			}
+		}
+	}
+	return ""
+}
+
+// counter is implemented by metrics clients that can add a value to a counter in one call
+type counter interface {
+	Count(name string, value int64, tags []string, rate float64) error
+}
+
+// count adds n to the counter name of the dataset. common.Metrics only increments counters by one,
+// so without Count support, the counter is incremented n times
+func (o *OracleWriter) count(name string, n int) error {
+	if o.metrics == nil || n == 0 {
+		return nil
+	}
+	tags := []string{"dataset:" + o.dataset}
+	if c, ok := o.metrics.(counter); ok {
+		return c.Count(name, int64(n), tags, 1)
+	}
+	for i := 0; i < n; i++ {
+		if err := o.metrics.Incr(name, tags, 1); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// collapseBatch reduces a batch to one row per key. The row with the newest recorded timestamp wins,
+// rows without recorded timestamp or with the same timestamp are ordered by their position in the batch.
+// The winning row takes the position of the first row of its key. The number of removed rows is returned.
+func collapseBatch(batch []*RowItem, keyCols []string) ([]*RowItem, int) {
+	positions := make(map[string]int, len(batch))
+	result := batch[:0]
+	for _, item := range batch {
+		key := rowKey(item, keyCols)
+		pos, found := positions[key]
+		if !found {
+			positions[key] = len(result)
+			result = append(result, item)
+			continue
+		}
+		prev := result[pos]
+		if item.recorded == 0 || prev.recorded == 0 || item.recorded >= prev.recorded {
+			result[pos] = item
+		}
+	}
+	collapsed := len(batch) - len(result)
+	for i := len(result); i < len(batch); i++ {
+		batch[i] = nil // release the removed rows
+	}
+	return result, collapsed
+}
+
+// rowKey builds a string from the bound key column values of a row. values are converted to the
+// column types before, the type is part of the key so that values of unconverted columns that print
+// the same way, like 1 and "1", are different keys
+func rowKey(item *RowItem, keyCols []string) string {
+	vals := make([]string, len(keyCols))
+	for i, k := range keyCols {
+		for _, col := range item.Columns {
+			if strings.EqualFold(col, k) {
+				v := bindVal(item.Map[col])
+				vals[i] = fmt.Sprintf("%T:%v", v, v)
+				break
+			}
+		}
+	}
+	return strings.Join(vals, "\x00")
+}
+
+// begin starts the transaction of the writer. in autonomous commit mode, there is no transaction
 func (o *OracleWriter) begin() error {
+	if o.commitMode == CommitAutonomous {
+		return nil
+	}
 	tx, err := o.db.Begin()
 	if err != nil {
 		return err
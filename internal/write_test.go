@@ -0,0 +1,454 @@
+package layer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	common "github.com/mimiro-io/common-datalayer"
+	egdm "github.com/mimiro-io/entity-graph-data-model"
+	go_ora "github.com/sijms/go-ora/v2"
+)
+
+func testRow(cols []string, vals []any, deleted bool) *RowItem {
+	r := &RowItem{Map: map[string]any{}, deleted: deleted}
+	for i, c := range cols {
+		r.SetValue(c, vals[i])
+	}
+	return r
+}
+
+var trickyValues = []string{
+	"O'Brien",
+	"it''s quoted twice",
+	`back\slash \' and \\`,
+	"value -- with comment",
+	"'); DROP TABLE sample; --",
+	"/* block */ comment",
+	"ünïcødé ✓ 日本語",
+	strings.Repeat("x", 40000),
+}
+
+func TestWriteStatements(t *testing.T) {
+	t.Run("append statement should bind all values as column arrays", func(t *testing.T) {
+		var batch []*RowItem
+		for i, v := range trickyValues {
+			batch = append(batch, testRow([]string{"id", "name"}, []any{i, v}, false))
+		}
+		stmt, args := appendStatement("sample", batch)
+		if stmt != `INSERT INTO "SAMPLE" ("ID", "NAME") VALUES (:1, :2)` {
+			t.Fatalf("unexpected statement: %s", stmt)
+		}
+		if len(args) != 2 {
+			t.Fatalf("expected 2 column arrays, got %d", len(args))
+		}
+		names := args[1].([]any)
+		if len(names) != len(trickyValues) {
+			t.Fatalf("expected %d values, got %d", len(trickyValues), len(names))
+		}
+		for i, v := range trickyValues {
+			if names[i] != v {
+				t.Fatalf("expected value %d to round-trip unchanged as %.20q, got %.20q", i, v, names[i])
+			}
+		}
+	})
+
+	t.Run("merge statement should bind all values including deleted flag", func(t *testing.T) {
+		var batch []*RowItem
+		for i, v := range trickyValues {
+			batch = append(batch, testRow([]string{"id", "name"}, []any{i, v}, i%2 == 0))
+		}
+		stmt, args := mergeStatement("sample", []string{"id"}, "", batch)
+		for _, v := range trickyValues {
+			if strings.Contains(stmt, v) {
+				t.Fatalf("value %.20q must not be part of statement text", v)
+			}
+		}
+		if len(args) != 3 {
+			t.Fatalf("expected 3 column arrays, got %d", len(args))
+		}
+		names := args[1].([]any)
+		deleted := args[2].([]any)
+		for i, v := range trickyValues {
+			if names[i] != v {
+				t.Fatalf("expected value %d to round-trip unchanged as %.20q, got %.20q", i, v, names[i])
+			}
+			expectedDeleted := "false"
+			if i%2 == 0 {
+				expectedDeleted = "true"
+			}
+			if deleted[i] != expectedDeleted {
+				t.Fatalf("expected deleted flag %s for row %d, got %v", expectedDeleted, i, deleted[i])
+			}
+		}
+		if !strings.HasPrefix(stmt, `MERGE INTO "SAMPLE" t USING (SELECT :1 AS "ID", :2 AS "NAME", :3 AS "_DELETED" FROM dual) n`) {
+			t.Fatalf("unexpected statement: %s", stmt)
+		}
+		if !strings.Contains(stmt, `UPDATE SET t."NAME" = n."NAME"`) {
+			t.Fatalf("id column must not be updated: %s", stmt)
+		}
+	})
+
+	t.Run("owner qualified tables should be quoted per part", func(t *testing.T) {
+		batch := []*RowItem{testRow([]string{"id", "name"}, []any{"1", "one"}, false)}
+		stmt, _ := appendStatement("owner.orders", batch)
+		if stmt != `INSERT INTO "OWNER"."ORDERS" ("ID", "NAME") VALUES (:1, :2)` {
+			t.Fatalf("unexpected statement: %s", stmt)
+		}
+		stmt, _ = mergeStatement("owner.orders", []string{"id"}, "", batch)
+		if !strings.HasPrefix(stmt, `MERGE INTO "OWNER"."ORDERS" t`) {
+			t.Fatalf("unexpected statement: %s", stmt)
+		}
+		stmts := fullSyncStatements("owner.orders", "owner.orders_staging", []string{"id"}, []string{"id", "name"}, false, deletion{mode: DeleteHard})
+		if !strings.Contains(stmts[0], `DELETE FROM "OWNER"."ORDERS" t WHERE NOT EXISTS (SELECT 1 FROM "OWNER"."ORDERS_STAGING" n`) {
+			t.Fatalf("unexpected statement: %s", stmts[0])
+		}
+	})
+
+	t.Run("merge statement should not insert deleted rows", func(t *testing.T) {
+		batch := []*RowItem{testRow([]string{"id", "name"}, []any{"1", "one"}, true)}
+		stmt, _ := mergeStatement("sample", []string{"id"}, "", batch)
+		if !strings.HasSuffix(stmt, `INSERT ("ID", "NAME") VALUES (n."ID", n."NAME") WHERE n."_DELETED" <> 'true'`) {
+			t.Fatalf("unexpected insert branch: %s", stmt)
+		}
+	})
+
+	t.Run("merge statement should join on all key columns", func(t *testing.T) {
+		batch := []*RowItem{testRow([]string{"tenant", "code", "name"}, []any{"a", 1, "one"}, false)}
+		stmt, _ := mergeStatement("sample", []string{"tenant", "code"}, "", batch)
+		if !strings.Contains(stmt, `ON (t."TENANT" = n."TENANT" AND t."CODE" = n."CODE")`) {
+			t.Fatalf("unexpected join condition: %s", stmt)
+		}
+		if !strings.Contains(stmt, `UPDATE SET t."NAME" = n."NAME"`+"\n") {
+			t.Fatalf("key columns must not be updated: %s", stmt)
+		}
+	})
+
+	t.Run("merge statement should leave out the update branch if all columns are keys", func(t *testing.T) {
+		batch := []*RowItem{testRow([]string{"parent", "child"}, []any{"a", "b"}, true)}
+		stmt, _ := mergeStatement("links", []string{"PARENT", "CHILD"}, "", batch)
+		if strings.Contains(stmt, "WHEN MATCHED") || !strings.Contains(stmt, `ON (t."PARENT" = n."PARENT" AND t."CHILD" = n."CHILD")`+"\nWHEN NOT MATCHED") {
+			t.Fatalf("unexpected statement: %s", stmt)
+		}
+		stmt, args := keyDeleteStatement("links", []string{"PARENT", "CHILD"}, batch)
+		if stmt != `DELETE FROM "LINKS" t WHERE t."PARENT" = :1 AND t."CHILD" = :2 AND :3 = 'true'` || len(args) != 3 {
+			t.Fatalf("unexpected delete statement: %s", stmt)
+		}
+	})
+
+	t.Run("merge statement should only apply newer rows if recorded column is given", func(t *testing.T) {
+		batch := []*RowItem{testRow([]string{"id", "name", "recorded"}, []any{"1", "one", 100}, false)}
+		stmt, _ := mergeStatement("sample", []string{"id"}, "recorded", batch)
+		if !strings.Contains(stmt, "\nWHERE n.\"RECORDED\" IS NULL OR t.\"RECORDED\" IS NULL OR n.\"RECORDED\" > t.\"RECORDED\"\nDELETE WHERE") {
+			t.Fatalf("unexpected update condition: %s", stmt)
+		}
+	})
+
+	t.Run("statement text should not depend on batch content", func(t *testing.T) {
+		stmt1, _ := mergeStatement("sample", []string{"id"}, "", []*RowItem{testRow([]string{"id", "name"}, []any{"1", "a"}, false)})
+		stmt2, _ := mergeStatement("sample", []string{"id"}, "", []*RowItem{
+			testRow([]string{"id", "name"}, []any{"2", "b"}, true),
+			testRow([]string{"id", "name"}, []any{"3", nil}, false),
+		})
+		if stmt1 != stmt2 {
+			t.Fatalf("expected identical statements, got\n%s\n%s", stmt1, stmt2)
+		}
+	})
+
+	t.Run("columns with mixed value types should be bound as strings", func(t *testing.T) {
+		batch := []*RowItem{
+			testRow([]string{"id", "name"}, []any{"1", 2.5}, false),
+			testRow([]string{"id", "name"}, []any{"2", "two"}, false),
+			testRow([]string{"id", "name"}, []any{"3", nil}, false),
+		}
+		_, args := appendStatement("sample", batch)
+		names := args[1].([]any)
+		if names[0] != "2.5" || names[1] != "two" || names[2] != nil {
+			t.Fatalf("unexpected values: %v", names)
+		}
+	})
+
+	t.Run("columns of all rows should be bound", func(t *testing.T) {
+		batch := []*RowItem{
+			testRow([]string{"id", "name"}, []any{"1", "one"}, false),
+			testRow([]string{"id", "name", "friend"}, []any{"2", "two", "1"}, false),
+		}
+		stmt, args := appendStatement("sample", batch)
+		if !strings.Contains(stmt, `("ID", "NAME", "FRIEND") VALUES (:1, :2, :3)`) || len(args) != 3 {
+			t.Fatalf("expected all columns in statement, got %s", stmt)
+		}
+		if friends := args[2].([]any); friends[0] != nil || friends[1] != "1" {
+			t.Fatalf("expected NULL for missing column, got %v", friends)
+		}
+		if len(batch[0].Columns) != 2 {
+			t.Fatalf("columns of first row must not change, got %v", batch[0].Columns)
+		}
+	})
+
+	t.Run("quoted identifiers should be escaped", func(t *testing.T) {
+		if quoteIdent(`na"me`) != `"NA""ME"` {
+			t.Fatalf("unexpected identifier: %s", quoteIdent(`na"me`))
+		}
+	})
+}
+
+func BenchmarkWriteStatements(b *testing.B) {
+	var batch []*RowItem
+	for i := 0; i < 1000; i++ {
+		batch = append(batch, testRow(
+			[]string{"id", "name", "age", "weight", "comment"},
+			[]any{i, "name", 42, 75.5, strings.Repeat("c", 100)}, false))
+	}
+	b.Run("append", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			appendStatement("sample", batch)
+		}
+	})
+	b.Run("merge", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			mergeStatement("sample", []string{"id"}, "", batch)
+		}
+	})
+}
+
+func TestFullSyncStatements(t *testing.T) {
+	t.Run("append mode should replace table content", func(t *testing.T) {
+		stmts := fullSyncStatements("sample", "sample_staging", []string{"id"}, []string{"id", "name"}, true, deletion{mode: DeleteHard})
+		if len(stmts) != 2 {
+			t.Fatalf("expected 2 statements, got %d", len(stmts))
+		}
+		if stmts[0] != `DELETE FROM "SAMPLE"` {
+			t.Fatalf("unexpected statement: %s", stmts[0])
+		}
+		if stmts[1] != `INSERT INTO "SAMPLE" SELECT * FROM "SAMPLE_STAGING"` {
+			t.Fatalf("unexpected statement: %s", stmts[1])
+		}
+	})
+	t.Run("upsert mode should delete unseen rows and merge the rest", func(t *testing.T) {
+		stmts := fullSyncStatements("sample", "sample_staging", []string{"id"}, []string{"id", "name"}, false, deletion{mode: DeleteHard})
+		if len(stmts) != 2 {
+			t.Fatalf("expected 2 statements, got %d", len(stmts))
+		}
+		if stmts[0] != `DELETE FROM "SAMPLE" t WHERE NOT EXISTS (SELECT 1 FROM "SAMPLE_STAGING" n WHERE t."ID" = n."ID")` {
+			t.Fatalf("unexpected statement: %s", stmts[0])
+		}
+		if !strings.HasPrefix(stmts[1], `MERGE INTO "SAMPLE" t USING "SAMPLE_STAGING" n ON (t."ID" = n."ID")`) ||
+			!strings.Contains(stmts[1], `UPDATE SET t."NAME" = n."NAME"`) ||
+			!strings.Contains(stmts[1], `INSERT ("ID", "NAME") VALUES (n."ID", n."NAME")`) {
+			t.Fatalf("unexpected statement: %s", stmts[1])
+		}
+	})
+	t.Run("soft delete mode should flag unseen rows", func(t *testing.T) {
+		soft := deletion{mode: DeleteSoft, column: "deleted", atColumn: "deleted_at", flag: "1"}
+		stmts := fullSyncStatements("sample", "sample_staging", []string{"id"}, []string{"id", "name", "deleted", "deleted_at"}, false, soft)
+		if len(stmts) != 2 || !strings.HasPrefix(stmts[0], "MERGE INTO") {
+			t.Fatalf("unexpected statements: %v", stmts)
+		}
+		if stmts[1] != `UPDATE "SAMPLE" t SET t."DELETED" = 1, t."DELETED_AT" = SYSTIMESTAMP `+
+			`WHERE NOT EXISTS (SELECT 1 FROM "SAMPLE_STAGING" n WHERE t."ID" = n."ID") AND (t."DELETED" IS NULL OR t."DELETED" <> 1)` {
+			t.Fatalf("unexpected statement: %s", stmts[1])
+		}
+		stmts = fullSyncStatements("sample", "sample_staging", []string{"id"}, []string{"id", "name", "deleted"}, true, soft)
+		if len(stmts) != 3 || stmts[0] != `DELETE FROM "SAMPLE" t WHERE EXISTS (SELECT 1 FROM "SAMPLE_STAGING" n WHERE t."ID" = n."ID")` ||
+			!strings.HasPrefix(stmts[2], `UPDATE "SAMPLE" t`) {
+			t.Fatalf("unexpected statements: %v", stmts)
+		}
+	})
+	t.Run("upsert mode should keep the last version of each key in staging", func(t *testing.T) {
+		stmts := stagingDedupeStatements("sample_staging", []string{"tenant", "code"})
+		if len(stmts) != 2 {
+			t.Fatalf("expected 2 statements, got %d", len(stmts))
+		}
+		if stmts[0] != `DELETE FROM "SAMPLE_STAGING" WHERE ROWID IN (SELECT rid FROM (SELECT ROWID AS rid, `+
+			`ROW_NUMBER() OVER (PARTITION BY "TENANT", "CODE" ORDER BY "_SEQ" DESC) AS rn FROM "SAMPLE_STAGING") WHERE rn > 1)` {
+			t.Fatalf("unexpected statement: %s", stmts[0])
+		}
+		if stmts[1] != `DELETE FROM "SAMPLE_STAGING" WHERE "_DELETED" = 'true'` {
+			t.Fatalf("unexpected statement: %s", stmts[1])
+		}
+	})
+	t.Run("ignore delete mode should keep unseen rows", func(t *testing.T) {
+		stmts := fullSyncStatements("sample", "sample_staging", []string{"id"}, []string{"id", "name"}, false, deletion{mode: DeleteIgnore})
+		if len(stmts) != 1 || !strings.HasPrefix(stmts[0], "MERGE INTO") {
+			t.Fatalf("unexpected statements: %v", stmts)
+		}
+	})
+}
+
+func TestRejectFile(t *testing.T) {
+	logger := common.NewLogger("test", "text", "error")
+	file := filepath.Join(t.TempDir(), "rejects.ndjson")
+	def := testDefinition(map[string]any{ErrorPolicy: ErrorPolicyReject, RejectFile: file})
+	def.IncomingMappingConfig = &common.IncomingMappingConfig{PropertyMappings: []*common.EntityToItemPropertyMapping{
+		{Property: "id", IsIdentity: true},
+		{EntityProperty: "http://test/age", Property: "age"},
+	}}
+	r, err := rejectionOf(def)
+	if err != nil || r.file != file {
+		t.Fatalf("unexpected rejection: %+v, %v", r, err)
+	}
+	writer := &OracleWriter{
+		logger:         logger,
+		dataset:        "test",
+		mapper:         common.NewMapper(logger, def.IncomingMappingConfig, nil),
+		columns:        map[string]tableColumn{"AGE": {Name: "AGE", DataType: "NUMBER"}},
+		flushThreshold: 1000,
+		commitMode:     CommitPerRequest,
+		rejection:      r,
+	}
+	entity := func(id string, age any) *egdm.Entity {
+		e := egdm.NewEntity().SetID(id)
+		e.Properties["http://test/age"] = age
+		return e
+	}
+
+	for _, e := range []*egdm.Entity{entity("http://test/1", 42), entity("http://test/2", "old"), entity("http://test/3", 43)} {
+		if err := writer.Write(e); err != nil {
+			t.Fatalf("expected failing entity to be rejected, got %v", err)
+		}
+	}
+	if len(writer.batch) != 2 || writer.received != 3 || writer.rejected != 1 {
+		t.Fatalf("expected 2 accepted and 1 rejected entity, got %d rows, %d received, %d rejected",
+			len(writer.batch), writer.received, writer.rejected)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec rejectRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatalf("expected one json line, got %s: %v", data, err)
+	}
+	if rec.Dataset != "test" || rec.EntityID != "http://test/2" || rec.ErrorCode != rejectConversion ||
+		!strings.Contains(rec.Message, "cannot convert value of column age") || !strings.Contains(string(rec.Entity), `"old"`) {
+		t.Fatalf("unexpected reject record: %s", data)
+	}
+
+	t.Run("should report the ORA- code of database errors", func(t *testing.T) {
+		rec := newRejectRecord("test", entity("http://test/4", 1), rejectDatabase,
+			fmt.Errorf("ORA-01400: cannot insert NULL into (\"APP\".\"T\".\"NAME\")"))
+		if rec.ErrorCode != "ORA-01400" {
+			t.Fatalf("expected ORA-01400, got %s", rec.ErrorCode)
+		}
+	})
+}
+
+func TestSoftDelete(t *testing.T) {
+	logger := common.NewLogger("test", "text", "error")
+	def := testDefinition(map[string]any{DeleteMode: DeleteSoft, DeletedAtColumn: "deleted_at"})
+	def.IncomingMappingConfig = &common.IncomingMappingConfig{PropertyMappings: []*common.EntityToItemPropertyMapping{
+		{Property: "id", IsIdentity: true},
+		{Property: "removed", IsDeleted: true},
+	}}
+	d, err := deletionOf(def)
+	if err != nil || d.column != "removed" || d.atColumn != "deleted_at" {
+		t.Fatalf("unexpected deletion: %+v, %v", d, err)
+	}
+	writer := &OracleWriter{
+		logger:         logger,
+		mapper:         common.NewMapper(logger, def.IncomingMappingConfig, nil),
+		columns:        map[string]tableColumn{"REMOVED": {Name: "REMOVED", DataType: "NUMBER"}},
+		flushThreshold: 1000,
+		deletion:       d,
+	}
+	e := egdm.NewEntity().SetID("http://test/1")
+	e.IsDeleted = true
+	if err := writer.Write(e); err != nil {
+		t.Fatal(err)
+	}
+	row := writer.batch[0]
+	if row.deleted || row.Map["removed"] == nil || row.Map["deleted_at"] == nil {
+		t.Fatalf("expected soft deleted row, got %+v", row)
+	}
+	if n, ok := row.Map["removed"].(go_ora.Number); !ok {
+		t.Fatalf("expected flag converted to number, got %#v", row.Map["removed"])
+	} else if v, _ := n.Int64(); v != 1 {
+		t.Fatalf("expected flag converted to number 1, got %#v", row.Map["removed"])
+	}
+
+	writer.deletion = deletion{mode: DeleteIgnore}
+	if err := writer.Write(e); err != nil || len(writer.batch) != 1 {
+		t.Fatalf("expected deleted entity to be ignored, got %v, %d rows", err, len(writer.batch))
+	}
+}
+
+func TestCollapseBatch(t *testing.T) {
+	cols := []string{"id", "name"}
+	row := func(id int, name string, deleted bool, recorded uint64) *RowItem {
+		r := testRow(cols, []any{id, name}, deleted)
+		r.recorded = recorded
+		return r
+	}
+
+	t.Run("should keep the last version of each key", func(t *testing.T) {
+		batch := []*RowItem{row(1, "one", false, 0), row(2, "two", false, 0), row(1, "one-changed", false, 0), row(1, "", true, 0)}
+		batch, collapsed := collapseBatch(batch, []string{"ID"})
+		if collapsed != 2 || len(batch) != 2 {
+			t.Fatalf("expected 2 rows and 2 collapsed, got %d rows and %d collapsed", len(batch), collapsed)
+		}
+		// update then delete of the same key in one batch results in the delete
+		if batch[0].Map["id"] != 1 || !batch[0].deleted || batch[1].Map["name"] != "two" {
+			t.Fatalf("unexpected batch: %+v, %+v", batch[0], batch[1])
+		}
+	})
+	t.Run("should prefer the newest recorded timestamp", func(t *testing.T) {
+		batch := []*RowItem{row(1, "newer", false, 200), row(1, "older", false, 100), row(2, "two", false, 100), row(2, "two-same", false, 100)}
+		batch, collapsed := collapseBatch(batch, []string{"id"})
+		if collapsed != 2 || batch[0].Map["name"] != "newer" || batch[1].Map["name"] != "two-same" {
+			t.Fatalf("unexpected batch: %+v, %+v", batch[0], batch[1])
+		}
+	})
+	t.Run("should compare all key columns", func(t *testing.T) {
+		batch := []*RowItem{row(1, "a", false, 0), row(1, "b", false, 0), row(1, "a", true, 0)}
+		batch, collapsed := collapseBatch(batch, []string{"id", "name"})
+		if collapsed != 1 || len(batch) != 2 || !batch[0].deleted {
+			t.Fatalf("unexpected batch: %+v", batch)
+		}
+	})
+	t.Run("should not collapse keys of different types", func(t *testing.T) {
+		batch := []*RowItem{testRow(cols, []any{1, "number"}, false), testRow(cols, []any{"1", "text"}, false)}
+		batch, collapsed := collapseBatch(batch, []string{"id"})
+		if collapsed != 0 || len(batch) != 2 {
+			t.Fatalf("expected 2 rows, got %d rows and %d collapsed", len(batch), collapsed)
+		}
+	})
+}
+
+func TestWriterFailure(t *testing.T) {
+	logger := common.NewLogger("test", "text", "error")
+	imc := &common.IncomingMappingConfig{PropertyMappings: []*common.EntityToItemPropertyMapping{
+		{Property: "id", IsIdentity: true},
+		{EntityProperty: "http://test/age", Property: "age"},
+	}}
+	writer := &OracleWriter{
+		logger:         logger,
+		mapper:         common.NewMapper(logger, imc, nil),
+		columns:        map[string]tableColumn{"AGE": {Name: "AGE", DataType: "NUMBER"}},
+		flushThreshold: 1000,
+		commitMode:     CommitPerRequest,
+	}
+	entity := func(age any) *egdm.Entity {
+		e := egdm.NewEntity().SetID("http://test/1")
+		e.Properties["http://test/age"] = age
+		return e
+	}
+
+	if err := writer.Write(entity(42)); err != nil {
+		t.Fatal(err)
+	}
+	err := writer.Write(entity("old"))
+	if err == nil || !strings.Contains(err.Error(), "cannot convert value of column age") {
+		t.Fatalf("expected conversion error, got %v", err)
+	}
+	if len(writer.batch) != 0 {
+		t.Fatalf("expected batch to be discarded, got %d rows", len(writer.batch))
+	}
+	for _, err := range []error{writer.Write(entity(43)), writer.Close()} {
+		if err == nil || !strings.Contains(err.Error(), "writer stopped after a failed write") {
+			t.Fatalf("expected writer to refuse further writes, got %v", err)
+		}
+	}
+}
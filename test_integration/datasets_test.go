@@ -27,17 +27,64 @@ func TestDatasetsEndpoint(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Could not read body from response")
 	}
-	// bodyStr := string(bodyBytes)
-	// fmt.Println(bodyStr)
-	var received []map[string]any
+	var received []struct {
+		Name     string         `json:"name"`
+		Metadata map[string]any `json:"metadata"`
+	}
 	json.Unmarshal(bodyBytes, &received)
-	expected := []map[string]any{
-		{"name": "sample", "description": "", "metadata": nil},
-		{"name": "sample2", "description": "", "metadata": nil},
-		{"name": "sample3", "description": "", "metadata": nil},
-		{"name": "sample4", "description": "", "metadata": nil},
-	}
-	if !reflect.DeepEqual(received, expected) {
-		t.Fatalf("Expected response to contain \n\n%s\n\nbut observed\n\n%s\n\n", expected, received)
+	var names []string
+	for _, ds := range received {
+		names = append(names, ds.Name)
+	}
+	expected := []string{"sample", "sample2", "sample3", "sample4", "sample5", "sample6", "sample7"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected response to contain \n\n%s\n\nbut observed\n\n%s\n\n", expected, names)
+	}
+}
+
+/**
+ * @api {test} /datasets
+ *   Test the dataset metadata, make sure it describes the table of the dataset
+ */
+func TestDatasetsMetadata(t *testing.T) {
+	conn := freshTables(t)
+	defer conn.Close()
+	defer testServer().Stop()
+
+	resp, err := http.Get(baseURL + "/datasets")
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	var received []struct {
+		Name     string `json:"name"`
+		Metadata struct {
+			TableName string `json:"table_name"`
+			Schema    struct {
+				Columns []struct {
+					Name     string `json:"name"`
+					DataType string `json:"data_type"`
+					Nullable bool   `json:"nullable"`
+				} `json:"columns"`
+				UnmappedColumns []string `json:"unmapped_columns"`
+				MissingColumns  []string `json:"missing_columns"`
+			} `json:"schema"`
+		} `json:"metadata"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&received); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	sample := received[0]
+	if sample.Name != "sample" || sample.Metadata.TableName != "sample" {
+		t.Fatalf("Expected sample dataset first, got %+v", sample)
+	}
+	var cols []string
+	for _, c := range sample.Metadata.Schema.Columns {
+		cols = append(cols, c.Name+" "+c.DataType)
+	}
+	if !reflect.DeepEqual(cols, []string{"ID VARCHAR2", "NAME VARCHAR2", "NUMBERTEST NUMBER"}) {
+		t.Fatalf("Unexpected columns: %v", cols)
+	}
+	if len(sample.Metadata.Schema.MissingColumns) != 0 {
+		t.Fatalf("Expected all mapped columns in table, missing: %v", sample.Metadata.Schema.MissingColumns)
 	}
 }
@@ -2,6 +2,7 @@ package test_integration
 
 import (
 	"context"
+	"database/sql"
 	"log"
 	"os"
 	"strconv"
@@ -107,6 +108,14 @@ func TestMain(m *testing.M) {
 		panic("Could not connect to test oracle: " + err.Error())
 	}
 
+	// the layer checks the tables of all configured datasets at startup, so they must exist before the first test
+	conn := sql.OpenDB(go_ora.NewConnector(connStr))
+	if err = createTables(conn); err != nil {
+		log.Fatalf("Could not create tables: %s", err)
+	}
+	conn.Exec("CREATE TABLE sample6 (id NUMBER(5,0), name VARCHAR2(100))") // ignore errors, table may exist
+	conn.Close()
+
 	code := m.Run()
 	if code != 0 {
 		log.Fatalf("Test failed with code: %d", code)
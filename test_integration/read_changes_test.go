@@ -2,6 +2,7 @@ package test_integration
 
 import (
 	"database/sql"
+	"fmt"
 	go_ora "github.com/sijms/go-ora/v2"
 	"net/http"
 	"os"
@@ -111,6 +112,41 @@ func TestReadChanges(t *testing.T) {
 		}
 	})
 
+	t.Run("latestOnly should return the latest version per id from an append mode table", func(t *testing.T) {
+		primeTables(t)
+		resp, err := http.Get(baseURL + "/datasets/sample2/changes?latestOnly=true")
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status code 200, got %d", resp.StatusCode)
+		}
+		entityParser := egdm.NewEntityParser(egdm.NewNamespaceContext()).WithExpandURIs()
+		ec, err := entityParser.LoadEntityCollection(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if len(ec.GetEntities()) != 10 {
+			t.Fatalf("Expected 10 entities, got %d", len(ec.GetEntities()))
+		}
+		for _, e := range ec.GetEntities() {
+			switch e.ID {
+			case "http://data.sample.org/things/1":
+				if e.Properties["http://data.sample.org/name"] != "one-off" {
+					t.Fatalf("Expected latest version of entity 1, got %+v", e)
+				}
+			case "http://data.sample.org/things/7":
+				if !e.IsDeleted {
+					t.Fatalf("Expected entity 7 to be deleted, got %+v", e)
+				}
+			case "http://data.sample.org/things/9":
+				if e.IsDeleted || e.Properties["http://data.sample.org/name"] != "n9ne" {
+					t.Fatalf("Expected latest version of entity 9, got %+v", e)
+				}
+			}
+		}
+	})
+
 	t.Run("read all changes with limit", func(t *testing.T) {
 		primeTables(t)
 		resp, err := http.Get(baseURL + "/datasets/sample/changes?limit=3")
@@ -294,6 +330,97 @@ func TestReadChanges(t *testing.T) {
 	})
 }
 
+func TestReadChangesRowSCN(t *testing.T) {
+	defer testServer().Stop()
+
+	t.Run("updates should be emitted with ora_rowscn change tracking", func(t *testing.T) {
+		primeTables(t)
+		resp, err := http.Get(baseURL + "/datasets/sample5/changes")
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		entityParser := egdm.NewEntityParser(egdm.NewNamespaceContext()).WithExpandURIs()
+		ec, err := entityParser.LoadEntityCollection(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if len(ec.GetEntities()) != 3 {
+			t.Fatalf("Expected 3 entities, got %d", len(ec.GetEntities()))
+		}
+		token := ec.GetContinuationToken().Token
+		if token == "" {
+			t.Fatalf("Expected continuation token")
+		}
+
+		conn := dbConn()
+		defer conn.Close()
+		_, err = conn.Exec("UPDATE sample5 SET name = 'two-changed' WHERE id = 2")
+		if err != nil {
+			t.Fatalf("Failed to update row: %v", err)
+		}
+
+		resp, err = http.Get(baseURL + "/datasets/sample5/changes?since=" + token)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		ec, err = entityParser.LoadEntityCollection(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if len(ec.GetEntities()) != 1 {
+			t.Fatalf("Expected 1 changed entity, got %d", len(ec.GetEntities()))
+		}
+		if ec.GetEntities()[0].ID != "http://data.sample5.org/2" {
+			t.Fatalf("Expected entity 2, got %s", ec.GetEntities()[0].ID)
+		}
+	})
+}
+
+func TestReadChangesCustomQuery(t *testing.T) {
+	defer testServer().Stop()
+
+	t.Run("changes should be read from custom query", func(t *testing.T) {
+		primeTables(t)
+		resp, err := http.Get(baseURL + "/datasets/sample7/changes")
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status code 200, got %d", resp.StatusCode)
+		}
+		entityParser := egdm.NewEntityParser(egdm.NewNamespaceContext()).WithExpandURIs()
+		ec, err := entityParser.LoadEntityCollection(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		// nine, nine (deleted) and n9ne
+		if len(ec.GetEntities()) != 3 {
+			t.Fatalf("Expected 3 entities, got %d", len(ec.GetEntities()))
+		}
+		if ec.GetEntities()[0].ID != "http://data.sample7.org/9" {
+			t.Fatalf("Expected entity 9, got %s", ec.GetEntities()[0].ID)
+		}
+
+		conn := dbConn()
+		defer conn.Close()
+		_, err = conn.Exec("INSERT INTO sample2 (id, name, recorded) VALUES (11, 'new', 164566000)")
+		if err != nil {
+			t.Fatalf("Failed to insert row: %v", err)
+		}
+		resp, err = http.Get(baseURL + "/datasets/sample7/changes?since=" + ec.GetContinuationToken().Token)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		ec, err = entityParser.LoadEntityCollection(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if len(ec.GetEntities()) != 1 || ec.GetEntities()[0].ID != "http://data.sample7.org/11" {
+			t.Fatalf("Expected only new entity 11, got %d entities", len(ec.GetEntities()))
+		}
+	})
+}
+
 func primeTables(t *testing.T) {
 	conn := freshTables(t) // reuse table creation from "write" tests
 	defer conn.Close()
@@ -360,6 +487,14 @@ func primeTables(t *testing.T) {
 		t.Fatalf("Failed to insert sample3 data: %v", err)
 	}
 
+	// populate "sample5" table, one insert per row so that each row gets its own scn
+	for i, name := range []string{"one", "two", "three"} {
+		_, err = conn.Exec(fmt.Sprintf("INSERT INTO sample5 (id, name) VALUES (%d, '%s')", i+1, name))
+		if err != nil {
+			t.Fatalf("Failed to insert sample5 data: %v", err)
+		}
+	}
+
 	// populate "sample4" table
 	result, err = conn.Exec("INSERT ALL " +
 		"   INTO sample4 (id, name, \"0\", \"14\") VALUES (1, 'one', '12', 14)" +
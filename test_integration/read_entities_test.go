@@ -0,0 +1,73 @@
+package test_integration
+
+import (
+	"net/http"
+	"testing"
+
+	egdm "github.com/mimiro-io/entity-graph-data-model"
+)
+
+func TestReadEntities(t *testing.T) {
+	defer testServer().Stop()
+	entityParser := egdm.NewEntityParser(egdm.NewNamespaceContext()).WithExpandURIs()
+	entities := func(dataset, query string) *egdm.EntityCollection {
+		resp, err := http.Get(baseURL + "/datasets/" + dataset + "/entities" + query)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status code 200, got %d", resp.StatusCode)
+		}
+		ec, err := entityParser.LoadEntityCollection(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		return ec
+	}
+
+	t.Run("should page through table by key", func(t *testing.T) {
+		primeTables(t)
+		seen := map[string]bool{}
+		token := ""
+		for page := 0; page < 4; page++ {
+			ec := entities("sample", "?limit=4&from="+token)
+			for _, e := range ec.GetEntities() {
+				if seen[e.ID] {
+					t.Fatalf("Entity %s emitted twice", e.ID)
+				}
+				seen[e.ID] = true
+			}
+			if page == 0 && len(ec.GetEntities()) != 4 {
+				t.Fatalf("Expected 4 entities on first page, got %d", len(ec.GetEntities()))
+			}
+			if page == 3 && len(ec.GetEntities()) != 0 {
+				t.Fatalf("Expected empty last page, got %d", len(ec.GetEntities()))
+			}
+			token = ec.GetContinuationToken().Token
+		}
+		if len(seen) != 10 {
+			t.Fatalf("Expected 10 entities, got %d", len(seen))
+		}
+		// the first page sorts by key, http://test/10 comes before http://test/2
+		ec := entities("sample", "?limit=2")
+		if ec.GetEntities()[1].ID != "http://test/10" {
+			t.Fatalf("Expected second entity to be http://test/10, got %s", ec.GetEntities()[1].ID)
+		}
+	})
+
+	t.Run("should return latest non deleted versions of change log", func(t *testing.T) {
+		primeTables(t)
+		ec := entities("sample2", "")
+		if len(ec.GetEntities()) != 9 {
+			t.Fatalf("Expected 9 entities, got %d", len(ec.GetEntities()))
+		}
+		for _, e := range ec.GetEntities() {
+			if e.IsDeleted || e.ID == "http://data.sample.org/things/7" {
+				t.Fatalf("Expected deleted entity 7 to be left out, got %+v", e)
+			}
+			if e.ID == "http://data.sample.org/things/9" && e.Properties["http://data.sample.org/name"] != "n9ne" {
+				t.Fatalf("Expected latest version of entity 9, got %+v", e)
+			}
+		}
+	})
+}
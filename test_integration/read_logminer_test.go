@@ -0,0 +1,112 @@
+package test_integration
+
+import (
+	"database/sql"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+
+	egdm "github.com/mimiro-io/entity-graph-data-model"
+	go_ora "github.com/sijms/go-ora/v2"
+)
+
+// enableLogMiner enables supplemental logging in the test container and grants the
+// log miner privileges to the test user. the test is skipped if this is not possible.
+func enableLogMiner(t *testing.T) {
+	port, _ := strconv.Atoi(os.Getenv("ORACLE_PORT"))
+	sys := sql.OpenDB(go_ora.NewConnector(go_ora.BuildUrl("localhost", port, "FREE", "sys", "systempassword",
+		map[string]string{"DBA PRIVILEGE": "SYSDBA"})))
+	defer sys.Close()
+	for _, stmt := range []string{
+		"ALTER DATABASE ADD SUPPLEMENTAL LOG DATA (ALL) COLUMNS",
+		"ALTER SESSION SET CONTAINER = FREEPDB1",
+		"GRANT LOGMINING TO testuser",
+		"GRANT EXECUTE ON DBMS_LOGMNR TO testuser",
+		"GRANT SELECT ANY DICTIONARY TO testuser",
+		"GRANT FLASHBACK ANY TABLE TO testuser",
+	} {
+		if _, err := sys.Exec(stmt); err != nil {
+			t.Skipf("log miner not available in test database: %v", err)
+		}
+	}
+}
+
+func TestReadChangesLogMiner(t *testing.T) {
+	enableLogMiner(t)
+	defer testServer().Stop()
+
+	conn := dbConn()
+	defer conn.Close()
+	conn.Exec("DROP TABLE sample6") // ignore errors, table may not exist
+	_, err := conn.Exec("CREATE TABLE sample6 (id NUMBER(5,0), name VARCHAR2(100))")
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	_, err = conn.Exec("INSERT INTO sample6 (id, name) VALUES (1, 'one')")
+	if err != nil {
+		t.Fatalf("Failed to insert row: %v", err)
+	}
+
+	entityParser := egdm.NewEntityParser(egdm.NewNamespaceContext()).WithExpandURIs()
+	changes := func(token string) *egdm.EntityCollection {
+		resp, err := http.Get(baseURL + "/datasets/sample6/changes?since=" + token)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status code 200, got %d", resp.StatusCode)
+		}
+		ec, err := entityParser.LoadEntityCollection(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		return ec
+	}
+
+	t.Run("first page should be a snapshot of the table", func(t *testing.T) {
+		ec := changes("")
+		if len(ec.GetEntities()) != 1 {
+			t.Fatalf("Expected 1 entity, got %d", len(ec.GetEntities()))
+		}
+		token := ec.GetContinuationToken().Token
+		if token == "" {
+			t.Fatalf("Expected continuation token")
+		}
+
+		t.Run("inserts, updates and deletes should be emitted in commit order", func(t *testing.T) {
+			for _, stmt := range []string{
+				"INSERT INTO sample6 (id, name) VALUES (2, 'two')",
+				"UPDATE sample6 SET name = 'one-changed' WHERE id = 1",
+				"DELETE FROM sample6 WHERE id = 2",
+			} {
+				if _, err := conn.Exec(stmt); err != nil {
+					t.Fatalf("Failed to change table: %v", err)
+				}
+			}
+			ec := changes(token)
+			entities := ec.GetEntities()
+			if len(entities) != 3 {
+				t.Fatalf("Expected 3 changes, got %d", len(entities))
+			}
+			if entities[0].ID != "http://data.sample6.org/2" || entities[0].IsDeleted {
+				t.Fatalf("Expected insert of entity 2 first, got %+v", entities[0])
+			}
+			if entities[1].ID != "http://data.sample6.org/1" ||
+				entities[1].Properties["http://data.sample.org/name"] != "one-changed" {
+				t.Fatalf("Expected update of entity 1, got %+v", entities[1])
+			}
+			if entities[2].ID != "http://data.sample6.org/2" || !entities[2].IsDeleted {
+				t.Fatalf("Expected delete of entity 2, got %+v", entities[2])
+			}
+			if entities[2].Properties["http://data.sample.org/name"] != "two" {
+				t.Fatalf("Expected deleted entity to carry its last values, got %+v", entities[2])
+			}
+
+			ec = changes(ec.GetContinuationToken().Token)
+			if len(ec.GetEntities()) != 0 {
+				t.Fatalf("Expected no more changes, got %d", len(ec.GetEntities()))
+			}
+		})
+	})
+}
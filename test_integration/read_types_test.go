@@ -74,8 +74,8 @@ func TestReadAllTypes(t *testing.T) {
 		if len(ec.GetEntities()) != 2 {
 			t.Fatalf("Expected 10 entities, got %d", len(ec.GetEntities()))
 		}
-		if len(ec.GetEntities()[0].Properties) != 20 {
-			t.Fatalf("Expected 20 properties, got %d", len(ec.GetEntities()[0].Properties))
+		if len(ec.GetEntities()[0].Properties) != 24 {
+			t.Fatalf("Expected 24 properties, got %d", len(ec.GetEntities()[0].Properties))
 		}
 		eq(t, ec.GetEntities()[0], "ID", "http://test/1")
 		eq(t, ec.GetEntities()[0], "COL_VARCHAR2", "one")
@@ -97,7 +97,11 @@ func TestReadAllTypes(t *testing.T) {
 		eq(t, ec.GetEntities()[0], "COL_TIMESTAMP_LTZ", time.Date(2021, 1, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600)).In(time.UTC).Format(time.RFC3339))
 		eq(t, ec.GetEntities()[0], "COL_INTERVAL_DS", "+01 12:00:00.000000")
 		eq(t, ec.GetEntities()[0], "COL_INTERVAL_YM", "+01-02")
-		eq(t, ec.GetEntities()[0], "COL_RAW", "AAABBBCCCDDD")
+		eq(t, ec.GetEntities()[0], "COL_RAW", "QUFBQkJCQ0NDRERE") // base64 of AAABBBCCCDDD
+		eq(t, ec.GetEntities()[0], "COL_NUMBER38", "12345678901234567890123456789012345678")
+		eq(t, ec.GetEntities()[0], "COL_BLOB", "QUFBQkJCQ0NDRERE")
+		eq(t, ec.GetEntities()[0], "COL_CLOB", "a long text")
+		eq(t, ec.GetEntities()[0], "COL_NCLOB", "a long national text")
 
 		if len(ec.GetEntities()[1].Properties) != 1 {
 			t.Fatalf("Expected 1 property (id) only from row with all nulls, got %d", len(ec.GetEntities()[1].Properties))
@@ -176,7 +180,11 @@ func createTypeTestTable(t *testing.T) {
 		"col_timestamp_ltz TIMESTAMP WITH LOCAL TIME ZONE, " +
 		"col_interval_ds INTERVAL DAY TO SECOND, " +
 		"col_interval_ym INTERVAL YEAR TO MONTH, " +
-		"col_raw RAW(128)" +
+		"col_raw RAW(128), " +
+		"col_number38 NUMBER(38), " +
+		"col_blob BLOB, " +
+		"col_clob CLOB, " +
+		"col_nclob NCLOB" +
 		")")
 	if err != nil {
 		t.Fatalf("Failed to create table: %v", err)
@@ -201,7 +209,11 @@ func createTypeTestTable(t *testing.T) {
 		"TO_TIMESTAMP_TZ('2021-01-01 12:00:00 +01:00', 'YYYY-MM-DD HH24:MI:SS TZH:TZM'), " +
 		"INTERVAL '1 12:00:00' DAY TO SECOND, " +
 		"INTERVAL '1-2' YEAR TO MONTH, " +
-		"RAWTOHEX('AAABBBCCCDDD')" +
+		"RAWTOHEX('AAABBBCCCDDD'), " +
+		"12345678901234567890123456789012345678, " +
+		"TO_BLOB(RAWTOHEX('AAABBBCCCDDD')), " +
+		"TO_CLOB('a long text'), " +
+		"TO_NCLOB('a long national text')" +
 		")"
 
 	_, err = conn.Exec(stmt)
@@ -230,6 +242,10 @@ func createTypeTestTable(t *testing.T) {
 		"null, " +
 		"null, " +
 		"null, " +
+		"null, " +
+		"null, " +
+		"null, " +
+		"null, " +
 		"null" +
 		")")
 	if err != nil {
@@ -0,0 +1,89 @@
+package test_integration
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	egdm "github.com/mimiro-io/entity-graph-data-model"
+)
+
+const benchRows = 1000
+
+/**
+ * Compares writing batches of 1000 rows with one INSERT ALL statement containing all values as literals
+ * (the approach used by earlier versions of the layer) with one INSERT statement using array binds.
+ * run with: go test ./test_integration -run XXX -bench .
+ */
+func BenchmarkInsertBatch(b *testing.B) {
+	conn := dbConn()
+	defer conn.Close()
+	conn.Exec("DROP TABLE bench") // ignore errors, table may not exist
+	_, err := conn.Exec("CREATE TABLE bench (id VARCHAR2(100), name VARCHAR2(100), age NUMBER(5,0))")
+	if err != nil {
+		b.Fatalf("Failed to create table: %v", err)
+	}
+
+	b.Run("insert all with literals", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var sb strings.Builder
+			sb.WriteString("INSERT ALL\n")
+			for r := 0; r < benchRows; r++ {
+				sb.WriteString(fmt.Sprintf("\tINTO \"BENCH\" (\"ID\", \"NAME\", \"AGE\") VALUES ('%d-%d', 'name %d', %d)\n", i, r, r, r%100))
+			}
+			sb.WriteString("SELECT 1 FROM dual")
+			_, err := conn.Exec(sb.String())
+			if err != nil {
+				b.Fatalf("Failed to insert: %v", err)
+			}
+		}
+	})
+
+	b.Run("insert with array binds", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ids := make([]any, benchRows)
+			names := make([]any, benchRows)
+			ages := make([]any, benchRows)
+			for r := 0; r < benchRows; r++ {
+				ids[r] = fmt.Sprintf("%d-%d", i, r)
+				names[r] = fmt.Sprintf("name %d", r)
+				ages[r] = r % 100
+			}
+			_, err := conn.Exec("INSERT INTO \"BENCH\" (\"ID\", \"NAME\", \"AGE\") VALUES (:1, :2, :3)", ids, names, ages)
+			if err != nil {
+				b.Fatalf("Failed to insert: %v", err)
+			}
+		}
+	})
+}
+
+// BenchmarkPostEntities measures the layer end to end, posting batches of 1000 entities to
+// the "sample" (upsert) and "sample2" (append) datasets.
+func BenchmarkPostEntities(b *testing.B) {
+	defer testServer().Stop()
+	freshTables(b).Close()
+	for _, dataset := range []string{"sample", "sample2"} {
+		b.Run(dataset, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ec := egdm.NewEntityCollection(egdm.NewNamespaceContext())
+				for r := 0; r < benchRows; r++ {
+					ec.AddEntityFromMap(map[string]any{
+						"id":    fmt.Sprintf("http://test/%d", r),
+						"props": map[string]any{"http://test/prop1": fmt.Sprintf("value %d-%d", i, r)},
+					})
+				}
+				entityReader, entityWriter := io.Pipe()
+				go func() { ec.WriteEntityGraphJSON(entityWriter); entityWriter.Close() }()
+				resp, err := http.Post(baseURL+"/datasets/"+dataset+"/entities", "application/json", entityReader)
+				if err != nil {
+					b.Fatalf("Failed to send request: %v", err)
+				}
+				if resp.StatusCode != http.StatusOK {
+					b.Fatalf("Expected status code 200, got %d", resp.StatusCode)
+				}
+			}
+		})
+	}
+}
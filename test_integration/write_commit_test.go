@@ -0,0 +1,77 @@
+package test_integration
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	common "github.com/mimiro-io/common-datalayer"
+	egdm "github.com/mimiro-io/entity-graph-data-model"
+	layer "github.com/mimiro-io/oracle-datalayer/internal"
+)
+
+/**
+ * @api {test} POST /datasets/{name}/entities
+ *   Test the commit modes of incremental writes. The third entity of the request can not be
+ *   converted, depending on the commit mode, the first batch of two entities is kept or rolled back.
+ */
+func TestWriteCommitModes(t *testing.T) {
+	server := testServer()
+	defer server.Stop()
+
+	for _, tc := range []struct {
+		commitMode string
+		expected   int
+	}{
+		{layer.CommitPerRequest, 0},
+		{layer.CommitPerFlush, 2},
+		{layer.CommitAutonomous, 2},
+	} {
+		t.Run(tc.commitMode, func(t *testing.T) {
+			conn := freshTables(t)
+			defer conn.Close()
+			if err := server.LayerService().UpdateConfiguration(&common.Config{
+				DatasetDefinitions: []*common.DatasetDefinition{{
+					SourceConfig: map[string]any{
+						layer.TableName:      "sample",
+						layer.FlushThreshold: float64(2),
+						layer.CommitMode:     tc.commitMode,
+					},
+					IncomingMappingConfig: &common.IncomingMappingConfig{
+						BaseURI: "http://test/",
+						PropertyMappings: []*common.EntityToItemPropertyMapping{
+							{Property: "id", IsIdentity: true},
+							{EntityProperty: "prop2", Property: "numbertest"},
+						},
+					},
+					OutgoingMappingConfig: &common.OutgoingMappingConfig{BaseURI: "http://test/", MapAll: true},
+					DatasetName:           "commits",
+				}},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			ec := egdm.NewEntityCollection(egdm.NewNamespaceContext())
+			ec.AddEntityFromMap(map[string]any{"id": "http://test/1", "props": map[string]any{"http://test/prop2": 1}})
+			ec.AddEntityFromMap(map[string]any{"id": "http://test/2", "props": map[string]any{"http://test/prop2": 2}})
+			ec.AddEntityFromMap(map[string]any{"id": "http://test/3", "props": map[string]any{"http://test/prop2": "three"}})
+			entityReader, entityWriter := io.Pipe()
+			go func() { ec.WriteEntityGraphJSON(entityWriter); entityWriter.Close() }()
+			resp, err := http.Post(baseURL+"/datasets/commits/entities", "application/json", entityReader)
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			if resp.StatusCode == http.StatusOK {
+				t.Fatalf("Expected failed request")
+			}
+
+			var cnt int
+			if err := conn.QueryRow("SELECT COUNT(*) FROM sample").Scan(&cnt); err != nil {
+				t.Fatalf("Failed to query table: %v", err)
+			}
+			if cnt != tc.expected {
+				t.Fatalf("Expected %d rows, got %d", tc.expected, cnt)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package test_integration
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"testing"
+
+	common "github.com/mimiro-io/common-datalayer"
+	egdm "github.com/mimiro-io/entity-graph-data-model"
+	layer "github.com/mimiro-io/oracle-datalayer/internal"
+)
+
+/**
+ * @api {test} POST /datasets/{name}/entities
+ *   Test soft deletes. Deleted entities flag their row instead of removing it,
+ *   and flagged rows are emitted as deleted entities by /changes and left out by /entities.
+ */
+func TestWriteSoftDelete(t *testing.T) {
+	conn := dbConn()
+	defer conn.Close()
+	conn.Exec("DROP TABLE soft_deleted") // ignore errors, table may not exist
+	if _, err := conn.Exec("CREATE TABLE soft_deleted (id VARCHAR2(100) PRIMARY KEY, name VARCHAR2(100), " +
+		"deleted NUMBER(1), deleted_at TIMESTAMP)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	server := testServer()
+	defer server.Stop()
+	if err := server.LayerService().UpdateConfiguration(&common.Config{
+		DatasetDefinitions: []*common.DatasetDefinition{{
+			SourceConfig: map[string]any{
+				layer.TableName:       "soft_deleted",
+				layer.DeleteMode:      layer.DeleteSoft,
+				layer.DeletedColumn:   "deleted",
+				layer.DeletedAtColumn: "deleted_at",
+			},
+			IncomingMappingConfig: &common.IncomingMappingConfig{
+				BaseURI: "http://test/",
+				PropertyMappings: []*common.EntityToItemPropertyMapping{
+					{Property: "id", IsIdentity: true},
+					{EntityProperty: "name", Property: "name"},
+				},
+			},
+			OutgoingMappingConfig: &common.OutgoingMappingConfig{
+				BaseURI: "http://test/",
+				PropertyMappings: []*common.ItemToEntityPropertyMapping{
+					{Property: "id", IsIdentity: true},
+					{Property: "name", EntityProperty: "name"},
+				},
+			},
+			DatasetName: "soft_deleted",
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	post := func(ec *egdm.EntityCollection, headers map[string]string) {
+		entityReader, entityWriter := io.Pipe()
+		go func() { ec.WriteEntityGraphJSON(entityWriter); entityWriter.Close() }()
+		req, _ := http.NewRequest(http.MethodPost, baseURL+"/datasets/soft_deleted/entities", entityReader)
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status code 200, got %d", resp.StatusCode)
+		}
+	}
+	read := func(endpoint string) []*egdm.Entity {
+		resp, err := http.Get(baseURL + "/datasets/soft_deleted/" + endpoint)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		ec, err := egdm.NewEntityParser(egdm.NewNamespaceContext()).WithExpandURIs().LoadEntityCollection(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		return ec.GetEntities()
+	}
+	flagged := func(id string) bool {
+		var deleted sql.NullInt64
+		var deletedAt sql.NullTime
+		if err := conn.QueryRow("SELECT deleted, deleted_at FROM soft_deleted WHERE id = :1", id).Scan(&deleted, &deletedAt); err != nil {
+			t.Fatalf("Expected row %s to be kept: %v", id, err)
+		}
+		if (deleted.Int64 == 1) != deletedAt.Valid {
+			t.Fatalf("Expected deleted_at to be set with the deleted flag, got %v, %v", deleted, deletedAt)
+		}
+		return deleted.Int64 == 1
+	}
+
+	ec := egdm.NewEntityCollection(egdm.NewNamespaceContext())
+	ec.AddEntityFromMap(map[string]any{"id": "http://test/1", "props": map[string]any{"http://test/name": "one"}})
+	ec.AddEntityFromMap(map[string]any{"id": "http://test/2", "props": map[string]any{"http://test/name": "two"}})
+	post(ec, nil)
+
+	t.Run("should flag deleted entities", func(t *testing.T) {
+		ec := egdm.NewEntityCollection(egdm.NewNamespaceContext())
+		ec.AddEntityFromMap(map[string]any{"id": "http://test/1", "props": map[string]any{"http://test/name": "one"}, "deleted": true})
+		post(ec, nil)
+		if !flagged("http://test/1") || flagged("http://test/2") {
+			t.Fatalf("Expected only http://test/1 to be flagged")
+		}
+	})
+	t.Run("should emit flagged rows as deleted entities", func(t *testing.T) {
+		for _, e := range read("changes") {
+			if e.IsDeleted != (e.ID == "http://test/1") {
+				t.Fatalf("unexpected deleted state of %s: %v", e.ID, e.IsDeleted)
+			}
+		}
+		entities := read("entities")
+		if len(entities) != 1 || entities[0].ID != "http://test/2" {
+			t.Fatalf("Expected only http://test/2 in entities, got %+v", entities)
+		}
+	})
+	t.Run("should flag rows missing in full sync", func(t *testing.T) {
+		ec := egdm.NewEntityCollection(egdm.NewNamespaceContext())
+		ec.AddEntityFromMap(map[string]any{"id": "http://test/3", "props": map[string]any{"http://test/name": "three"}})
+		post(ec, map[string]string{
+			"universal-data-api-full-sync-start": "true",
+			"universal-data-api-full-sync-end":   "true",
+			"universal-data-api-full-sync-id":    "soft-sync",
+		})
+		if !flagged("http://test/1") || !flagged("http://test/2") || flagged("http://test/3") {
+			t.Fatalf("Expected rows 1 and 2 to be flagged by full sync")
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package test_integration
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	egdm "github.com/mimiro-io/entity-graph-data-model"
+)
+
+/**
+ * @api {test} POST /datasets/{name}/entities
+ *   Test full sync batches to the /entities endpoint
+ *   In this test we use the "sample" dataset, which is configured in latest only mode.
+ *   The table is primed with 10 rows, then a full sync with 2 batches containing 3 entities is posted.
+ *   After the last batch, only the 3 synced rows should remain.
+ */
+func TestPostEntitiesFullSync(t *testing.T) {
+	defer testServer().Stop()
+
+	postBatch := func(t *testing.T, syncID string, start, end bool, entities ...map[string]any) int {
+		ec := egdm.NewEntityCollection(egdm.NewNamespaceContext())
+		for _, e := range entities {
+			ec.AddEntityFromMap(e)
+		}
+		entityReader, entityWriter := io.Pipe()
+		go func() { ec.WriteEntityGraphJSON(entityWriter); entityWriter.Close() }()
+
+		req, _ := http.NewRequest(http.MethodPost, baseURL+"/datasets/sample/entities", entityReader)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("universal-data-api-full-sync-id", syncID)
+		if start {
+			req.Header.Set("universal-data-api-full-sync-start", "true")
+		}
+		if end {
+			req.Header.Set("universal-data-api-full-sync-end", "true")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	t.Run("full sync should replace table content", func(t *testing.T) {
+		primeTables(t)
+
+		status := postBatch(t, "sync-1", true, false,
+			map[string]any{"id": "http://test/1", "props": map[string]any{"http://test/prop1": "synced1"}},
+			map[string]any{"id": "http://test/11", "props": map[string]any{"http://test/prop1": "synced11"}})
+		if status != http.StatusOK {
+			t.Fatalf("Expected status code 200, got %d", status)
+		}
+		status = postBatch(t, "sync-1", false, true,
+			map[string]any{"id": "http://test/12", "props": map[string]any{"http://test/prop1": "synced12"}})
+		if status != http.StatusOK {
+			t.Fatalf("Expected status code 200, got %d", status)
+		}
+
+		conn := dbConn()
+		defer conn.Close()
+		rows, err := conn.Query("SELECT id,name FROM sample")
+		if err != nil {
+			t.Fatalf("Failed to query table: %v", err)
+		}
+		defer rows.Close()
+		var id, name string
+		seen := map[string]string{}
+		for rows.Next() {
+			err := rows.Scan(&id, &name)
+			if err != nil {
+				t.Fatalf("Failed to scan row: %v", err)
+			}
+			seen[id] = name
+		}
+		if len(seen) != 3 {
+			t.Fatalf("Expected 3 rows, got %d: %v", len(seen), seen)
+		}
+		if seen["http://test/1"] != "synced1" {
+			t.Fatalf("Expected synced1, got %s", seen["http://test/1"])
+		}
+	})
+
+	t.Run("later batches should replace and delete keys of earlier batches", func(t *testing.T) {
+		primeTables(t)
+		for i, batch := range [][]map[string]any{
+			{
+				{"id": "http://test/1", "props": map[string]any{"http://test/prop1": "old1"}},
+				{"id": "http://test/2", "props": map[string]any{"http://test/prop1": "synced2"}},
+			},
+			{{"id": "http://test/1", "props": map[string]any{"http://test/prop1": "synced1"}}},
+			{{"id": "http://test/2", "deleted": true, "props": map[string]any{"http://test/prop1": "synced2"}}},
+		} {
+			if status := postBatch(t, "sync-4", i == 0, i == 2, batch...); status != http.StatusOK {
+				t.Fatalf("Expected status code 200, got %d", status)
+			}
+		}
+
+		conn := dbConn()
+		defer conn.Close()
+		var cnt int
+		var name string
+		if err := conn.QueryRow("SELECT COUNT(*), MAX(name) FROM sample").Scan(&cnt, &name); err != nil {
+			t.Fatalf("Failed to query table: %v", err)
+		}
+		if cnt != 1 || name != "synced1" {
+			t.Fatalf("Expected only the last version of http://test/1, got %d rows, %s", cnt, name)
+		}
+	})
+
+	t.Run("batches with unknown sync id should be rejected", func(t *testing.T) {
+		primeTables(t)
+		status := postBatch(t, "sync-2", true, false,
+			map[string]any{"id": "http://test/1", "props": map[string]any{"http://test/prop1": "synced1"}})
+		if status != http.StatusOK {
+			t.Fatalf("Expected status code 200, got %d", status)
+		}
+		status = postBatch(t, "sync-3", false, true,
+			map[string]any{"id": "http://test/1", "props": map[string]any{"http://test/prop1": "synced1"}})
+		if status == http.StatusOK {
+			t.Fatalf("Expected batch with unknown sync id to fail")
+		}
+	})
+}
@@ -173,6 +173,54 @@ func TestPostEntitiesLatestOnly(t *testing.T) {
 			t.Fatalf("Expected 3 rows, got %d", cnt)
 		}
 	})
+	t.Run("write values with quotes, backslashes and comment sequences", func(t *testing.T) {
+		conn := freshTables(t)
+		defer conn.Close()
+
+		values := map[string]string{
+			"http://test/1": "O'Brien",
+			"http://test/2": `back\slash \'`,
+			"http://test/3": "value -- with comment",
+			"http://test/4": "'); DROP TABLE sample; --",
+			"http://test/5": "ünïcødé ✓",
+		}
+		ec := egdm.NewEntityCollection(egdm.NewNamespaceContext())
+		for id, v := range values {
+			ec.AddEntityFromMap(map[string]any{"id": id, "props": map[string]any{"http://test/prop1": v}})
+		}
+		entityReader, entityWriter := io.Pipe()
+		go func() { ec.WriteEntityGraphJSON(entityWriter); entityWriter.Close() }()
+
+		resp, err := http.Post(baseURL+"/datasets/sample/entities", "application/json", entityReader)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status code 200, got %d", resp.StatusCode)
+		}
+
+		rows, err := conn.Query("SELECT id,name FROM sample")
+		if err != nil {
+			t.Fatalf("Failed to query table: %v", err)
+		}
+		defer rows.Close()
+		var id, name string
+		cnt := 0
+		for rows.Next() {
+			err := rows.Scan(&id, &name)
+			if err != nil {
+				t.Fatalf("Failed to scan row: %v", err)
+			}
+			if values[id] != name {
+				t.Fatalf("Expected %s, got %s", values[id], name)
+			}
+			cnt++
+		}
+		if cnt != len(values) {
+			t.Fatalf("Expected %d rows, got %d", len(values), cnt)
+		}
+	})
+
 	t.Run("make sure we can read the columns with numbers as name", func(t *testing.T) {
 		primeTables(t)
 		resp, err := http.Get(baseURL + "/datasets/sample4/changes")
@@ -264,6 +312,72 @@ func TestPostEntitiesLatestOnly(t *testing.T) {
 			t.Fatalf("Expected 2 remaining rows, got %d", cnt)
 		}
 	})
+	t.Run("update and delete the same entity in one batch", func(t *testing.T) {
+		conn := freshTables(t)
+		defer conn.Close()
+
+		post := func(ec *egdm.EntityCollection) {
+			entityReader, entityWriter := io.Pipe()
+			go func() { ec.WriteEntityGraphJSON(entityWriter); entityWriter.Close() }()
+			resp, err := http.Post(baseURL+"/datasets/sample/entities", "application/json", entityReader)
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Expected status code 200, got %d", resp.StatusCode)
+			}
+		}
+		ec := egdm.NewEntityCollection(egdm.NewNamespaceContext())
+		ec.AddEntityFromMap(map[string]any{"id": "http://test/1", "props": map[string]any{"http://test/prop1": "value1"}})
+		ec.AddEntityFromMap(map[string]any{"id": "http://test/2", "props": map[string]any{"http://test/prop1": "value2"}})
+		post(ec)
+
+		ec = egdm.NewEntityCollection(egdm.NewNamespaceContext())
+		ec.AddEntityFromMap(map[string]any{"id": "http://test/1", "props": map[string]any{"http://test/prop1": "value1-changed"}})
+		ec.AddEntityFromMap(map[string]any{"id": "http://test/2", "props": map[string]any{"http://test/prop1": "value2-changed"}})
+		ec.AddEntityFromMap(map[string]any{"id": "http://test/1", "props": map[string]any{"http://test/prop1": "value1-changed"}, "deleted": true})
+		ec.AddEntityFromMap(map[string]any{"id": "http://test/2", "props": map[string]any{"http://test/prop1": "value2-changed-again"}})
+		post(ec)
+
+		var cnt int
+		if err := conn.QueryRow("SELECT COUNT(*) FROM sample WHERE id = 'http://test/1'").Scan(&cnt); err != nil || cnt != 0 {
+			t.Fatalf("Expected row http://test/1 to be deleted, got %d rows, %v", cnt, err)
+		}
+		var name string
+		if err := conn.QueryRow("SELECT name FROM sample WHERE id = 'http://test/2'").Scan(&name); err != nil {
+			t.Fatalf("Failed to query table: %v", err)
+		}
+		if name != "value2-changed-again" {
+			t.Fatalf("Expected last version value2-changed-again, got %s", name)
+		}
+	})
+
+	t.Run("create and delete a new entity in one batch", func(t *testing.T) {
+		conn := freshTables(t)
+		defer conn.Close()
+
+		ec := egdm.NewEntityCollection(egdm.NewNamespaceContext())
+		ec.AddEntityFromMap(map[string]any{"id": "http://test/new", "props": map[string]any{"http://test/prop1": "created"}})
+		ec.AddEntityFromMap(map[string]any{"id": "http://test/new", "props": map[string]any{"http://test/prop1": "created"}, "deleted": true})
+		ec.AddEntityFromMap(map[string]any{"id": "http://test/other", "props": map[string]any{"http://test/prop1": "other"}})
+		entityReader, entityWriter := io.Pipe()
+		go func() { ec.WriteEntityGraphJSON(entityWriter); entityWriter.Close() }()
+		resp, err := http.Post(baseURL+"/datasets/sample/entities", "application/json", entityReader)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status code 200, got %d", resp.StatusCode)
+		}
+
+		var cnt int
+		if err := conn.QueryRow("SELECT COUNT(*) FROM sample WHERE id = 'http://test/new'").Scan(&cnt); err != nil || cnt != 0 {
+			t.Fatalf("Expected deleted entity not to be inserted, got %d rows, %v", cnt, err)
+		}
+		if err := conn.QueryRow("SELECT COUNT(*) FROM sample WHERE id = 'http://test/other'").Scan(&cnt); err != nil || cnt != 1 {
+			t.Fatalf("Expected other entity to be inserted, got %d rows, %v", cnt, err)
+		}
+	})
 }
 
 /* @api {test} POST /datasets/{name}/entities
@@ -531,36 +645,44 @@ func TestPostEntitiesAppendMode(t *testing.T) {
 	})
 }
 
-func freshTables(t *testing.T) *sql.DB {
+func dbConn() *sql.DB {
 	url := os.Getenv("ORACLE_URL")
-	c := sql.OpenDB(go_ora.NewConnector(url))
+	return sql.OpenDB(go_ora.NewConnector(url))
+}
+
+func freshTables(t testing.TB) *sql.DB {
+	c := dbConn()
+	if err := createTables(c); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	return c
+}
+
+// createTables (re)creates the tables of the datasets in the integration test config
+func createTables(c *sql.DB) error {
 	c.Exec("DROP TABLE sample")  // ignore errors, table may not exist
 	c.Exec("DROP TABLE sample2") // ignore errors, table may not exist
 	c.Exec("DROP TABLE sample3") // ignore errors, table may not exist
 	c.Exec("DROP TABLE sample4") // ignore errors, table may not exist
-
-	_, err := c.Exec("CREATE TABLE sample (id VARCHAR2(100), name VARCHAR2(100), numbertest NUMBER(5,1))")
-	if err != nil {
-		t.Fatalf("Failed to create table: %v", err)
-	}
-	_, err = c.Exec("CREATE TABLE sample2 (" +
-		"id VARCHAR2(100), " +
-		"name VARCHAR2(100), " +
-		"recorded NUMBER(16), " +
-		"deleted BOOL, " +
-		"age NUMBER(5,0), " +
-		"weight BINARY_FLOAT" +
-		")")
-	if err != nil {
-		t.Fatalf("Failed to create table: %v", err)
-	}
-	_, err = c.Exec("CREATE TABLE sample3 (id NUMBER(5,0), name VARCHAR2(100))")
-	if err != nil {
-		t.Fatalf("Failed to create table: %v", err)
-	}
-	_, err = c.Exec("CREATE TABLE sample4 (id VARCHAR2(100), name VARCHAR2(100), \"0\" VARCHAR2(100), \"14\" NUMBER(5))")
-	if err != nil {
-		t.Fatalf("Failed to create table: %v", err)
+	c.Exec("DROP TABLE sample5") // ignore errors, table may not exist
+
+	for _, stmt := range []string{
+		"CREATE TABLE sample (id VARCHAR2(100), name VARCHAR2(100), numbertest NUMBER(5,1))",
+		"CREATE TABLE sample2 (" +
+			"id VARCHAR2(100), " +
+			"name VARCHAR2(100), " +
+			"recorded NUMBER(16), " +
+			"deleted BOOL, " +
+			"age NUMBER(5,0), " +
+			"weight BINARY_FLOAT" +
+			")",
+		"CREATE TABLE sample3 (id NUMBER(5,0), name VARCHAR2(100))",
+		"CREATE TABLE sample5 (id NUMBER(5,0), name VARCHAR2(100)) ROWDEPENDENCIES",
+		"CREATE TABLE sample4 (id VARCHAR2(100), name VARCHAR2(100), \"0\" VARCHAR2(100), \"14\" NUMBER(5))",
+	} {
+		if _, err := c.Exec(stmt); err != nil {
+			return err
+		}
 	}
-	return c
+	return nil
 }
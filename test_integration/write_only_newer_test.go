@@ -0,0 +1,90 @@
+package test_integration
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	common "github.com/mimiro-io/common-datalayer"
+	egdm "github.com/mimiro-io/entity-graph-data-model"
+	layer "github.com/mimiro-io/oracle-datalayer/internal"
+)
+
+/**
+ * @api {test} POST /datasets/{name}/entities
+ *   Test conditional upserts. With only_newer, replayed entities with an older recorded
+ *   timestamp than the stored row do not change or delete the row.
+ */
+func TestWriteOnlyNewer(t *testing.T) {
+	conn := dbConn()
+	defer conn.Close()
+	conn.Exec("DROP TABLE only_newer") // ignore errors, table may not exist
+	if _, err := conn.Exec("CREATE TABLE only_newer (id VARCHAR2(100) PRIMARY KEY, name VARCHAR2(100), recorded NUMBER(19))"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	server := testServer()
+	defer server.Stop()
+	if err := server.LayerService().UpdateConfiguration(&common.Config{
+		DatasetDefinitions: []*common.DatasetDefinition{{
+			SourceConfig: map[string]any{
+				layer.TableName: "only_newer",
+				layer.OnlyNewer: true,
+			},
+			IncomingMappingConfig: &common.IncomingMappingConfig{
+				BaseURI: "http://test/",
+				PropertyMappings: []*common.EntityToItemPropertyMapping{
+					{Property: "id", IsIdentity: true},
+					{EntityProperty: "name", Property: "name"},
+					{Property: "recorded", IsRecorded: true},
+				},
+			},
+			OutgoingMappingConfig: &common.OutgoingMappingConfig{BaseURI: "http://test/", MapAll: true},
+			DatasetName:           "only_newer",
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	post := func(name string, recorded float64, deleted bool) {
+		ec := egdm.NewEntityCollection(egdm.NewNamespaceContext())
+		ec.AddEntityFromMap(map[string]any{
+			"id":       "http://test/1",
+			"props":    map[string]any{"http://test/name": name},
+			"recorded": recorded,
+			"deleted":  deleted,
+		})
+		entityReader, entityWriter := io.Pipe()
+		go func() { ec.WriteEntityGraphJSON(entityWriter); entityWriter.Close() }()
+		resp, err := http.Post(baseURL+"/datasets/only_newer/entities", "application/json", entityReader)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status code 200, got %d", resp.StatusCode)
+		}
+	}
+	stored := func() (string, int) {
+		var name string
+		var cnt int
+		conn.QueryRow("SELECT COUNT(*), MAX(name) FROM only_newer").Scan(&cnt, &name)
+		return name, cnt
+	}
+
+	post("v2", 200, false)
+	post("v1", 100, false) // replay of an older version
+	if name, _ := stored(); name != "v2" {
+		t.Fatalf("Expected older version to be skipped, got %s", name)
+	}
+	post("v2", 100, true) // replay of an older deletion
+	if _, cnt := stored(); cnt != 1 {
+		t.Fatalf("Expected older deletion to be skipped")
+	}
+	post("v3", 300, false)
+	if name, _ := stored(); name != "v3" {
+		t.Fatalf("Expected newer version to be applied, got %s", name)
+	}
+	post("v3", 400, true)
+	if _, cnt := stored(); cnt != 0 {
+		t.Fatalf("Expected newer deletion to be applied")
+	}
+}
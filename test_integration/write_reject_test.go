@@ -0,0 +1,82 @@
+package test_integration
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	common "github.com/mimiro-io/common-datalayer"
+	egdm "github.com/mimiro-io/entity-graph-data-model"
+	layer "github.com/mimiro-io/oracle-datalayer/internal"
+)
+
+/**
+ * @api {test} POST /datasets/{name}/entities
+ *   Test the reject error policy. Entities violating a constraint are written to the reject table,
+ *   the other entities of the batch are committed.
+ */
+func TestWriteRejects(t *testing.T) {
+	conn := dbConn()
+	defer conn.Close()
+	for _, table := range []string{"reject_target", "reject_errors"} {
+		conn.Exec("DROP TABLE " + table) // ignore errors, table may not exist
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE reject_target (id VARCHAR2(100) PRIMARY KEY, name VARCHAR2(100) NOT NULL)",
+		"CREATE TABLE reject_errors (dataset VARCHAR2(255), entity_id VARCHAR2(4000), error_code VARCHAR2(100), " +
+			"error_message VARCHAR2(4000), entity CLOB, rejected_at TIMESTAMP)",
+	} {
+		if _, err := conn.Exec(stmt); err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+	}
+
+	server := testServer()
+	defer server.Stop()
+	if err := server.LayerService().UpdateConfiguration(&common.Config{
+		DatasetDefinitions: []*common.DatasetDefinition{{
+			SourceConfig: map[string]any{
+				layer.TableName:   "reject_target",
+				layer.ErrorPolicy: layer.ErrorPolicyReject,
+				layer.RejectTable: "reject_errors",
+			},
+			IncomingMappingConfig: &common.IncomingMappingConfig{
+				BaseURI: "http://test/",
+				PropertyMappings: []*common.EntityToItemPropertyMapping{
+					{Property: "id", IsIdentity: true},
+					{EntityProperty: "name", Property: "name"},
+				},
+			},
+			OutgoingMappingConfig: &common.OutgoingMappingConfig{BaseURI: "http://test/", MapAll: true},
+			DatasetName:           "reject_target",
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ec := egdm.NewEntityCollection(egdm.NewNamespaceContext())
+	ec.AddEntityFromMap(map[string]any{"id": "http://test/1", "props": map[string]any{"http://test/name": "one"}})
+	ec.AddEntityFromMap(map[string]any{"id": "http://test/2", "props": map[string]any{"http://test/name": nil}}) // violates NOT NULL
+	ec.AddEntityFromMap(map[string]any{"id": "http://test/3", "props": map[string]any{"http://test/name": "three"}})
+	entityReader, entityWriter := io.Pipe()
+	go func() { ec.WriteEntityGraphJSON(entityWriter); entityWriter.Close() }()
+	resp, err := http.Post(baseURL+"/datasets/reject_target/entities", "application/json", entityReader)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", resp.StatusCode)
+	}
+
+	var cnt int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM reject_target").Scan(&cnt); err != nil || cnt != 2 {
+		t.Fatalf("Expected 2 accepted rows, got %d, %v", cnt, err)
+	}
+	var id, code string
+	if err := conn.QueryRow("SELECT entity_id, error_code FROM reject_errors").Scan(&id, &code); err != nil {
+		t.Fatalf("Expected one rejected entity: %v", err)
+	}
+	if id != "http://test/2" || code != "ORA-01400" {
+		t.Fatalf("Expected http://test/2 rejected with ORA-01400, got %s, %s", id, code)
+	}
+}
@@ -0,0 +1,86 @@
+package test_integration
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	common "github.com/mimiro-io/common-datalayer"
+	egdm "github.com/mimiro-io/entity-graph-data-model"
+	layer "github.com/mimiro-io/oracle-datalayer/internal"
+)
+
+func TestWriteCreatesTable(t *testing.T) {
+	conn := dbConn()
+	defer conn.Close()
+	conn.Exec("DROP TABLE auto_created") // ignore errors, table may not exist
+
+	server := testServer()
+	defer server.Stop()
+	configure := func(mappings ...*common.EntityToItemPropertyMapping) {
+		if err := server.LayerService().UpdateConfiguration(&common.Config{
+			DatasetDefinitions: []*common.DatasetDefinition{{
+				SourceConfig: map[string]any{
+					layer.TableName:    "auto_created",
+					layer.CreateTable:  true,
+					layer.EvolveSchema: true,
+				},
+				IncomingMappingConfig: &common.IncomingMappingConfig{
+					BaseURI:          "http://test/",
+					PropertyMappings: mappings,
+				},
+				OutgoingMappingConfig: &common.OutgoingMappingConfig{BaseURI: "http://test/", MapAll: true},
+				DatasetName:           "auto_created",
+			}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	post := func(props map[string]any) {
+		ec := egdm.NewEntityCollection(egdm.NewNamespaceContext())
+		ec.AddEntityFromMap(map[string]any{"id": "http://test/1", "props": props})
+		entityReader, entityWriter := io.Pipe()
+		go func() {
+			ec.WriteEntityGraphJSON(entityWriter)
+			entityWriter.Close()
+		}()
+		resp, err := http.Post(baseURL+"/datasets/auto_created/entities", "application/json", entityReader)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status code 200, got %d", resp.StatusCode)
+		}
+	}
+	id := &common.EntityToItemPropertyMapping{Property: "id", IsIdentity: true}
+	name := &common.EntityToItemPropertyMapping{EntityProperty: "name", Property: "name"}
+	deleted := &common.EntityToItemPropertyMapping{Property: "deleted", IsDeleted: true}
+
+	t.Run("should create missing table", func(t *testing.T) {
+		configure(id, name, deleted)
+		post(map[string]any{"http://test/name": "one"})
+		var cnt int
+		if err := conn.QueryRow("SELECT COUNT(*) FROM auto_created WHERE name = 'one'").Scan(&cnt); err != nil {
+			t.Fatalf("Failed to query table: %v", err)
+		}
+		if cnt != 1 {
+			t.Fatalf("Expected 1 row, got %d", cnt)
+		}
+		if err := conn.QueryRow("SELECT COUNT(*) FROM user_constraints " +
+			"WHERE table_name = 'AUTO_CREATED' AND constraint_type = 'P'").Scan(&cnt); err != nil || cnt != 1 {
+			t.Fatalf("Expected primary key on table, got %d, %v", cnt, err)
+		}
+	})
+
+	t.Run("should add new mapped columns", func(t *testing.T) {
+		configure(id, name, deleted, &common.EntityToItemPropertyMapping{EntityProperty: "age", Property: "age", Datatype: "int"})
+		post(map[string]any{"http://test/name": "one", "http://test/age": 42})
+		var age int
+		if err := conn.QueryRow("SELECT age FROM auto_created WHERE id = 'http://test/1'").Scan(&age); err != nil {
+			t.Fatalf("Failed to query table: %v", err)
+		}
+		if age != 42 {
+			t.Fatalf("Expected age 42, got %d", age)
+		}
+	})
+}
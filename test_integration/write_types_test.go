@@ -0,0 +1,112 @@
+package test_integration
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	common "github.com/mimiro-io/common-datalayer"
+	egdm "github.com/mimiro-io/entity-graph-data-model"
+	layer "github.com/mimiro-io/oracle-datalayer/internal"
+	go_ora "github.com/sijms/go-ora/v2"
+)
+
+func TestWriteAllTypes(t *testing.T) {
+	createTypeTestTable(t)
+
+	mappings := []*common.EntityToItemPropertyMapping{{Property: "id", IsIdentity: true}}
+	for _, col := range []string{"col_varchar2", "col_number5p2", "col_number38", "col_number1", "col_bool",
+		"col_date", "col_timestamp", "col_timestamp_tz", "col_raw", "col_blob", "col_clob"} {
+		mappings = append(mappings, &common.EntityToItemPropertyMapping{EntityProperty: col, Property: col})
+	}
+	server := testServer()
+	// entity values are converted to the column types of the all_types table
+	if err := server.LayerService().UpdateConfiguration(&common.Config{
+		DatasetDefinitions: []*common.DatasetDefinition{{
+			SourceConfig: map[string]any{layer.TableName: "all_types"},
+			IncomingMappingConfig: &common.IncomingMappingConfig{
+				BaseURI:          "http://any.type/",
+				PropertyMappings: mappings,
+			},
+			OutgoingMappingConfig: &common.OutgoingMappingConfig{BaseURI: "http://any.type/", MapAll: true},
+			DatasetName:           "all_types_write",
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	post := func(props map[string]any) *http.Response {
+		ec := egdm.NewEntityCollection(egdm.NewNamespaceContext())
+		ec.AddEntityFromMap(map[string]any{"id": "http://test/3", "props": props})
+		entityReader, entityWriter := io.Pipe()
+		go func() {
+			ec.WriteEntityGraphJSON(entityWriter)
+			entityWriter.Close()
+		}()
+		resp, err := http.Post(baseURL+"/datasets/all_types_write/entities", "application/json", entityReader)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		return resp
+	}
+
+	t.Run("should convert values to column types", func(t *testing.T) {
+		longText := strings.Repeat("x", 40000)
+		resp := post(map[string]any{
+			"http://any.type/col_varchar2":     "one",
+			"http://any.type/col_number5p2":    "123.45",
+			"http://any.type/col_number38":     "12345678901234567890123456789012345678",
+			"http://any.type/col_number1":      true,
+			"http://any.type/col_bool":         true,
+			"http://any.type/col_date":         "2021-01-01T00:00:00Z",
+			"http://any.type/col_timestamp":    "2021-01-01T12:00:00.123456Z",
+			"http://any.type/col_timestamp_tz": "2021-01-01T12:00:00+01:00",
+			"http://any.type/col_raw":          "QUFBQkJCQ0NDRERE",
+			"http://any.type/col_blob":         "QUFBQkJCQ0NDRERE",
+			"http://any.type/col_clob":         longText,
+		})
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			t.Fatalf("Expected status code 200, got %d: %s", resp.StatusCode, body)
+		}
+
+		conn := sql.OpenDB(go_ora.NewConnector(os.Getenv("ORACLE_URL")))
+		defer conn.Close()
+		var number5p2, number38 string
+		var number1 int
+		var date, timestamp time.Time
+		var raw, blob []byte
+		var clob string
+		err := conn.QueryRow("SELECT TO_CHAR(col_number5p2), TO_CHAR(col_number38), col_number1, col_date, "+
+			"col_timestamp, col_raw, col_blob, col_clob FROM all_types WHERE id = 'http://test/3'").
+			Scan(&number5p2, &number38, &number1, &date, &timestamp, &raw, &blob, &clob)
+		if err != nil {
+			t.Fatalf("Failed to query table: %v", err)
+		}
+		if number5p2 != "123.45" || number38 != "12345678901234567890123456789012345678" || number1 != 1 {
+			t.Fatalf("Unexpected numbers: %s, %s, %d", number5p2, number38, number1)
+		}
+		if date.Year() != 2021 || timestamp.Nanosecond() != 123456000 {
+			t.Fatalf("Unexpected timestamps: %v, %v", date, timestamp)
+		}
+		if string(raw) != "AAABBBCCCDDD" || string(blob) != "AAABBBCCCDDD" {
+			t.Fatalf("Unexpected bytes: %s, %s", raw, blob)
+		}
+		if clob != longText {
+			t.Fatalf("Expected clob of length %d, got %d", len(longText), len(clob))
+		}
+	})
+
+	t.Run("should reject values that cannot be converted", func(t *testing.T) {
+		// the layer error names entity and column in the log, the response is a generic bad request
+		resp := post(map[string]any{"http://any.type/col_date": "01.01.2021"})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Expected status code 400, got %d", resp.StatusCode)
+		}
+	})
+}